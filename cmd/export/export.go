@@ -1,9 +1,12 @@
 package export
 
 import (
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/Eun/gcal-to-ics/internal/cliauth"
 	"github.com/Eun/gcal-to-ics/pkg/gti"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -15,14 +18,37 @@ var Command = cli.Command{
 	Aliases: []string{"e"},
 	Usage:   "export calendar to specific file",
 	Flags: []cli.Flag{
-		flagTokenFile,
-		flagAuthBindAddress,
+		cliauth.FlagTokenFile,
+		cliauth.FlagAuthBindAddress,
 		flagAccount,
 		flagCalendar,
+		flagCalendarID,
 		flagFormat,
 		flagStartFrom,
 		flagEndOn,
 		flagOutput,
+		flagKeepRecurrence,
+		flagStoreDir,
+		flagSnapshotDir,
+		flagOffline,
+		flagPrivacy,
+		flagBusyLabel,
+		flagBusySlot,
+		flagFreeBusyQuery,
+
+		flagFilterIncludeSummary,
+		flagFilterExcludeSummary,
+		flagFilterIncludeColor,
+		flagFilterExcludeColor,
+		flagFilterIncludeEventType,
+		flagFilterExcludeEventType,
+		flagFilterSkipDeclined,
+		flagFilterTransparency,
+		flagFilterVisibility,
+		flagFilterMinDuration,
+		flagFilterMaxDuration,
+		flagFilterWeekday,
+		flagFilterHours,
 
 		flagHideUID,
 		flagHideOrganizer,
@@ -33,8 +59,10 @@ var Command = cli.Command{
 		flagHideConference,
 		flagHideTransparency,
 		flagHideStatus,
+		flagHideReminders,
 
 		flagOverwriteCalendarName,
+		flagOverwriteSummary,
 		flagOverwriteOrganizer,
 		flagOverwriteVisibility,
 		flagOverwriteDescription,
@@ -42,37 +70,39 @@ var Command = cli.Command{
 		flagOverwriteConference,
 		flagOverwriteTransparency,
 		flagOverwriteStatus,
-	},
-	Action: action,
-}
+		flagOverwriteAlarm,
 
-var flagTokenFile = cli.StringFlag{
-	Name:  "tokenfile",
-	Usage: "the file where the token will be stored",
-	Value: "token.json",
-}
+		flagTemplateSummary,
+		flagTemplateDescription,
+		flagTemplateLocation,
+		flagTemplateConference,
 
-var flagAuthBindAddress = cli.StringFlag{
-	Name:  "auth-bind-address",
-	Usage: "bind to this address for the google authentication",
-	Value: "127.0.0.1:8000",
+		flagRedact,
+		flagRedactPattern,
+	},
+	Action: action,
 }
 
 var flagAccount = cli.StringFlag{
 	Name:     "account",
-	Usage:    "google account to use in the format <user@domain.com>",
+	Usage:    "google account to use in the format <user@domain.com>, also names the snapshot with --offline",
 	Required: true,
 }
 
 var flagCalendar = cli.StringFlag{
-	Name:     "calendar",
-	Usage:    "which calendar to use",
-	Required: true,
+	Name: "calendar",
+	Usage: "which calendar to use, either its name, primary, a regex (/^Team/) or a glob pattern (Team*), " +
+		"required unless --calendar-id is used",
+}
+
+var flagCalendarID = cli.StringFlag{
+	Name:  "calendar-id",
+	Usage: "the id of the calendar to use, e.g. name@gmail.com or primary",
 }
 
 var flagFormat = cli.StringFlag{
 	Name:  "format",
-	Usage: "which format to export to",
+	Usage: "which format to export to (" + strings.Join(gti.Formats(), ", ") + ")",
 	Value: "ics",
 }
 
@@ -94,6 +124,100 @@ var flagOutput = cli.StringFlag{
 	Value: "-",
 }
 
+var flagKeepRecurrence = cli.BoolFlag{
+	Name:  "keep-recurrence",
+	Usage: "export recurring events as a series instead of expanding every instance",
+}
+
+var flagStoreDir = cli.StringFlag{
+	Name:  "store-dir",
+	Usage: "store the events in this directory, so later exports only fetch the changes",
+}
+
+var flagSnapshotDir = cli.StringFlag{
+	Name:  "snapshot-dir",
+	Usage: "save the fetched calendar and events in this directory after every successful export",
+}
+
+var flagPrivacy = cli.StringFlag{
+	Name:  "privacy",
+	Usage: "busy only exports when the calendar is busy, without any details of the events",
+}
+
+var flagBusyLabel = cli.StringFlag{
+	Name:  "busy-label",
+	Usage: "the summary of the events with --privacy=busy",
+	Value: "Busy",
+}
+
+var flagBusySlot = cli.DurationFlag{
+	Name:  "busy-slot",
+	Usage: "round the times of the events outwards to this slot with --privacy=busy, e.g. 30m",
+}
+
+var flagFreeBusyQuery = cli.BoolFlag{
+	Name:  "freebusy-query",
+	Usage: "read only the busy time with Google's freebusy.query, which also works for calendars of other users",
+}
+
+var flagOffline = cli.BoolFlag{
+	Name:  "offline",
+	Usage: "export from the snapshot in --snapshot-dir instead of fetching the calendar, with the same --account and --freebusy-query",
+}
+
+var flagFilterIncludeSummary = cli.StringFlag{
+	Name:  "filter.include-summary",
+	Usage: "only export events with a summary that matches this regex",
+}
+var flagFilterExcludeSummary = cli.StringFlag{
+	Name:  "filter.exclude-summary",
+	Usage: "do not export events with a summary that matches this regex",
+}
+var flagFilterIncludeColor = cli.StringSliceFlag{
+	Name:  "filter.include-color",
+	Usage: "only export events with this color id, can be repeated",
+}
+var flagFilterExcludeColor = cli.StringSliceFlag{
+	Name:  "filter.exclude-color",
+	Usage: "do not export events with this color id, can be repeated",
+}
+var flagFilterIncludeEventType = cli.StringSliceFlag{
+	Name:  "filter.include-event-type",
+	Usage: "only export events of this type (default, focusTime, outOfOffice, ...), can be repeated",
+}
+var flagFilterExcludeEventType = cli.StringSliceFlag{
+	Name:  "filter.exclude-event-type",
+	Usage: "do not export events of this type (default, focusTime, outOfOffice, ...), can be repeated",
+}
+var flagFilterSkipDeclined = cli.BoolFlag{
+	Name:  "filter.skip-declined",
+	Usage: "do not export events that were declined",
+}
+var flagFilterTransparency = cli.StringFlag{
+	Name:  "filter.transparency",
+	Usage: "only export events with this transparency (opaque, transparent)",
+}
+var flagFilterVisibility = cli.StringFlag{
+	Name:  "filter.visibility",
+	Usage: "only export events with this visibility (default, public, private)",
+}
+var flagFilterMinDuration = cli.DurationFlag{
+	Name:  "filter.min-duration",
+	Usage: "do not export events that are shorter than this duration",
+}
+var flagFilterMaxDuration = cli.DurationFlag{
+	Name:  "filter.max-duration",
+	Usage: "do not export events that are longer than this duration",
+}
+var flagFilterWeekday = cli.StringSliceFlag{
+	Name:  "filter.weekday",
+	Usage: "only export events that start on this weekday (mon, tue, ...), can be repeated",
+}
+var flagFilterHours = cli.StringFlag{
+	Name:  "filter.hours",
+	Usage: "only export events that start in this range of hours, e.g. 9-17",
+}
+
 var flagHideUID = cli.BoolFlag{
 	Name:  "hide.uid",
 	Usage: "whether or not to hide uid",
@@ -130,7 +254,15 @@ var flagHideStatus = cli.BoolFlag{
 	Name:  "hide.status",
 	Usage: "whether or not to hide status",
 }
+var flagHideReminders = cli.BoolFlag{
+	Name:  "hide.reminders",
+	Usage: "whether or not to hide reminders",
+}
 
+var flagOverwriteSummary = cli.StringFlag{
+	Name:  "overwrite.summary",
+	Usage: "overwrite Summary with the specified value",
+}
 var flagOverwriteCalendarName = cli.StringFlag{
 	Name:  "overwrite.calendar-name",
 	Usage: "overwrite CalendarName with the specified value",
@@ -163,6 +295,36 @@ var flagOverwriteStatus = cli.StringFlag{
 	Name:  "overwrite.status",
 	Usage: "overwrite Status with the specified value",
 }
+var flagOverwriteAlarm = cli.DurationFlag{
+	Name:  "overwrite.alarm",
+	Usage: "overwrite Reminders with a single alarm the specified duration before the event",
+}
+
+var flagTemplateSummary = cli.StringFlag{
+	Name:  "template.summary",
+	Usage: "rewrite Summary with the specified text/template, e.g. \"[{{ .Calendar.Summary }}] {{ .Summary }}\"",
+}
+var flagTemplateDescription = cli.StringFlag{
+	Name:  "template.description",
+	Usage: "rewrite Description with the specified text/template",
+}
+var flagTemplateLocation = cli.StringFlag{
+	Name:  "template.location",
+	Usage: "rewrite Location with the specified text/template",
+}
+var flagTemplateConference = cli.StringFlag{
+	Name:  "template.conference",
+	Usage: "rewrite Conference with the specified text/template",
+}
+
+var flagRedact = cli.StringSliceFlag{
+	Name:  "redact",
+	Usage: "redact summary, description and location with a built-in rule (emails, phones, meet, zoom, passcodes), can be repeated",
+}
+var flagRedactPattern = cli.StringSliceFlag{
+	Name:  "redact.pattern",
+	Usage: "replace the matches of this regex in summary, description and location with [redacted], can be repeated",
+}
 
 func action(c *cli.Context) error {
 	logger := log.With().Str("name", c.Command.Name).Logger()
@@ -175,6 +337,15 @@ func action(c *cli.Context) error {
 	if err != nil {
 		return errors.Wrapf(err, "unable to parse end-on `%s'", c.String(flagEndOn.Name))
 	}
+	if c.String(flagCalendar.Name) == "" && c.String(flagCalendarID.Name) == "" {
+		return errors.Errorf("Required flag %q or %q not set", flagCalendar.Name, flagCalendarID.Name)
+	}
+
+	offline := c.Bool(flagOffline.Name)
+	if offline && c.String(flagSnapshotDir.Name) == "" {
+		return errors.New("--offline requires --snapshot-dir")
+	}
+
 	outputFile := c.String(flagOutput.Name)
 
 	if outputFile == "" || outputFile == "-" {
@@ -189,15 +360,22 @@ func action(c *cli.Context) error {
 		c.App.Writer = f
 	}
 
-	client, err := getAuthenticatedClient(
-		&logger,
-		c.String(flagAuthBindAddress.Name),
-		c.String(flagTokenFile.Name),
-		c.GlobalString("client_id"),
-		c.GlobalString("client_secret"),
-	)
-	if err != nil {
-		return errors.Wrap(err, "unable to get authenticated client")
+	var client *http.Client
+	if !offline {
+		client, err = cliauth.Authenticate(c, &logger)
+		if err != nil {
+			return err
+		}
+	}
+
+	var redactionRules []gti.RedactionRule
+	for _, pattern := range c.StringSlice(flagRedactPattern.Name) {
+		redactionRules = append(redactionRules, gti.RedactionRule{Pattern: pattern})
+	}
+
+	var source gti.Source
+	if c.Bool(flagFreeBusyQuery.Name) {
+		source = gti.GoogleFreeBusySource{}
 	}
 
 	return gti.Export(&gti.Config{
@@ -207,6 +385,7 @@ func action(c *cli.Context) error {
 		StartFrom:    startFrom,
 		EndOn:        endOn,
 		CalendarName: c.String(flagCalendar.Name),
+		CalendarID:   c.String(flagCalendarID.Name),
 		Writer:       c.App.Writer,
 		Client:       client,
 		Version:      c.App.Version,
@@ -220,9 +399,11 @@ func action(c *cli.Context) error {
 			Conference:   c.Bool(flagHideConference.Name),
 			Transparency: c.Bool(flagHideTransparency.Name),
 			Status:       c.Bool(flagHideStatus.Name),
+			Reminders:    c.Bool(flagHideReminders.Name),
 		},
 		OverwriteFields: gti.OverwriteFields{
 			CalendarName: c.String(flagOverwriteCalendarName.Name),
+			Summary:      c.String(flagOverwriteSummary.Name),
 			Organizer:    c.String(flagOverwriteOrganizer.Name),
 			Visibility:   c.String(flagOverwriteVisibility.Name),
 			Description:  c.String(flagOverwriteDescription.Name),
@@ -230,6 +411,40 @@ func action(c *cli.Context) error {
 			Conference:   c.String(flagOverwriteConference.Name),
 			Transparency: c.String(flagOverwriteTransparency.Name),
 			Status:       c.String(flagOverwriteStatus.Name),
+			Alarm:        c.Duration(flagOverwriteAlarm.Name),
+		},
+		TemplateFields: gti.TemplateFields{
+			Summary:     c.String(flagTemplateSummary.Name),
+			Description: c.String(flagTemplateDescription.Name),
+			Location:    c.String(flagTemplateLocation.Name),
+			Conference:  c.String(flagTemplateConference.Name),
+		},
+		Redaction: gti.Redaction{
+			Builtin: c.StringSlice(flagRedact.Name),
+			Rules:   redactionRules,
+		},
+		KeepRecurrence: c.Bool(flagKeepRecurrence.Name),
+		StoreDir:       c.String(flagStoreDir.Name),
+		SnapshotDir:    c.String(flagSnapshotDir.Name),
+		Offline:        offline,
+		Source:         source,
+		Privacy:        c.String(flagPrivacy.Name),
+		BusyLabel:      c.String(flagBusyLabel.Name),
+		BusySlot:       c.Duration(flagBusySlot.Name),
+		Filter: gti.Filter{
+			IncludeSummary:    c.String(flagFilterIncludeSummary.Name),
+			ExcludeSummary:    c.String(flagFilterExcludeSummary.Name),
+			IncludeColors:     c.StringSlice(flagFilterIncludeColor.Name),
+			ExcludeColors:     c.StringSlice(flagFilterExcludeColor.Name),
+			IncludeEventTypes: c.StringSlice(flagFilterIncludeEventType.Name),
+			ExcludeEventTypes: c.StringSlice(flagFilterExcludeEventType.Name),
+			SkipDeclined:      c.Bool(flagFilterSkipDeclined.Name),
+			Transparency:      c.String(flagFilterTransparency.Name),
+			Visibility:        c.String(flagFilterVisibility.Name),
+			MinDuration:       c.Duration(flagFilterMinDuration.Name),
+			MaxDuration:       c.Duration(flagFilterMaxDuration.Name),
+			Weekdays:          c.StringSlice(flagFilterWeekday.Name),
+			Hours:             c.String(flagFilterHours.Name),
 		},
 	})
 }
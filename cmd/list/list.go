@@ -0,0 +1,195 @@
+package list
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Eun/gcal-to-ics/internal/cliauth"
+	"github.com/Eun/gcal-to-ics/pkg/gti"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli"
+)
+
+const (
+	listFormatTable = "table"
+	listFormatJSON  = "json"
+)
+
+var CalendarsCommand = cli.Command{
+	Name:  "calendars",
+	Usage: "inspect the calendars of the account",
+	Subcommands: []cli.Command{
+		{
+			Name:  "list",
+			Usage: "list the calendars of the account",
+			Flags: []cli.Flag{
+				cliauth.FlagTokenFile,
+				cliauth.FlagAuthBindAddress,
+				flagListFormat,
+			},
+			Action: listCalendarsAction,
+		},
+	},
+}
+
+var EventsCommand = cli.Command{
+	Name:  "events",
+	Usage: "inspect the events of a calendar",
+	Subcommands: []cli.Command{
+		{
+			Name:  "list",
+			Usage: "list the events of a calendar in a time range",
+			Flags: []cli.Flag{
+				cliauth.FlagTokenFile,
+				cliauth.FlagAuthBindAddress,
+				flagCalendar,
+				flagCalendarID,
+				flagStartFrom,
+				flagEndOn,
+				flagKeepRecurrence,
+				flagListFormat,
+			},
+			Action: listEventsAction,
+		},
+	},
+}
+
+var flagListFormat = cli.StringFlag{
+	Name:  "format",
+	Usage: "output format (" + listFormatTable + ", " + listFormatJSON + ")",
+	Value: listFormatTable,
+}
+
+var flagCalendar = cli.StringFlag{
+	Name: "calendar",
+	Usage: "which calendar to use, either its name, primary, a regex (/^Team/) or a glob pattern (Team*), " +
+		"required unless --calendar-id is used",
+}
+
+var flagCalendarID = cli.StringFlag{
+	Name:  "calendar-id",
+	Usage: "the id of the calendar to use, e.g. name@gmail.com or primary",
+}
+
+var flagStartFrom = cli.StringFlag{
+	Name:  "start-from",
+	Usage: "from which time to list the events",
+	Value: time.Now().Format(time.RFC3339),
+}
+
+var flagEndOn = cli.StringFlag{
+	Name:  "end-on",
+	Usage: "on which time to end listing the events",
+	Value: time.Now().AddDate(0, 1, 0).Format(time.RFC3339),
+}
+
+var flagKeepRecurrence = cli.BoolFlag{
+	Name:  "keep-recurrence",
+	Usage: "list recurring events as a series instead of expanding every instance",
+}
+
+func checkListFormat(c *cli.Context) error {
+	switch c.String(flagListFormat.Name) {
+	case listFormatTable, listFormatJSON:
+		return nil
+	default:
+		return errors.Errorf("format `%s' is not supported", c.String(flagListFormat.Name))
+	}
+}
+
+func writeJSON(c *cli.Context, v interface{}) error {
+	enc := json.NewEncoder(c.App.Writer)
+	enc.SetIndent("", "  ")
+	return errors.Wrap(enc.Encode(v), "unable to encode")
+}
+
+func listCalendarsAction(c *cli.Context) error {
+	logger := log.With().Str("name", "calendars list").Logger()
+	if err := checkListFormat(c); err != nil {
+		return err
+	}
+	client, err := cliauth.Authenticate(c, &logger)
+	if err != nil {
+		return err
+	}
+
+	entries, err := gti.ListCalendars(context.Background(), &gti.Config{Logger: &logger, Client: client})
+	if err != nil {
+		return err
+	}
+	if c.String(flagListFormat.Name) == listFormatJSON {
+		return writeJSON(c, entries)
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 0, 2, ' ', 0) //nolint: gomnd // padding
+	fmt.Fprintln(w, "ID\tSUMMARY\tACCESS ROLE\tTIME ZONE\tFLAGS")
+	for _, entry := range entries {
+		var flags []string
+		if entry.Primary {
+			flags = append(flags, "primary")
+		}
+		if entry.Hidden {
+			flags = append(flags, "hidden")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", entry.ID, entry.Summary, entry.AccessRole, entry.TimeZone, strings.Join(flags, ","))
+	}
+	return errors.Wrap(w.Flush(), "unable to write")
+}
+
+func listEventsAction(c *cli.Context) error {
+	logger := log.With().Str("name", "events list").Logger()
+	if err := checkListFormat(c); err != nil {
+		return err
+	}
+	if c.String(flagCalendar.Name) == "" && c.String(flagCalendarID.Name) == "" {
+		return errors.Errorf("Required flag %q or %q not set", flagCalendar.Name, flagCalendarID.Name)
+	}
+	startFrom, err := time.Parse(time.RFC3339, c.String(flagStartFrom.Name))
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse start-from `%s'", c.String(flagStartFrom.Name))
+	}
+	endOn, err := time.Parse(time.RFC3339, c.String(flagEndOn.Name))
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse end-on `%s'", c.String(flagEndOn.Name))
+	}
+	client, err := cliauth.Authenticate(c, &logger)
+	if err != nil {
+		return err
+	}
+
+	_, events, err := gti.Fetch(context.Background(), &gti.Config{
+		Logger:         &logger,
+		StartFrom:      startFrom,
+		EndOn:          endOn,
+		CalendarName:   c.String(flagCalendar.Name),
+		CalendarID:     c.String(flagCalendarID.Name),
+		Client:         client,
+		KeepRecurrence: c.Bool(flagKeepRecurrence.Name),
+	})
+	if err != nil {
+		return err
+	}
+	if c.String(flagListFormat.Name) == listFormatJSON {
+		return writeJSON(c, events)
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 0, 2, ' ', 0) //nolint: gomnd // padding
+	fmt.Fprintln(w, "START\tEND\tSUMMARY\tSTATUS\tID")
+	for i := range events {
+		ev := &events[i]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", formatEventTime(ev.Start), formatEventTime(ev.End), ev.Summary, ev.Status, ev.ID)
+	}
+	return errors.Wrap(w.Flush(), "unable to write")
+}
+
+func formatEventTime(t gti.EventTime) string {
+	if t.AllDay {
+		return t.Time.Format("2006-01-02")
+	}
+	return t.Time.Format("2006-01-02 15:04 MST")
+}
@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	"github.com/Eun/gcal-to-ics/cmd/export"
+	"github.com/Eun/gcal-to-ics/cmd/list"
+	"github.com/Eun/gcal-to-ics/cmd/secret"
 	"github.com/Eun/gcal-to-ics/cmd/serve"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
@@ -66,7 +68,10 @@ func Run() int {
 	}
 	app.Commands = []cli.Command{
 		export.Command,
+		list.CalendarsCommand,
+		list.EventsCommand,
 		serve.Command,
+		secret.Command,
 	}
 	app.Before = func(context *cli.Context) error {
 		loglevel := zerolog.InfoLevel
@@ -0,0 +1,220 @@
+package secret
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/bcrypt"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// keySize is the number of random bytes of a feed key.
+const keySize = 32
+
+var Command = cli.Command{
+	Name:  "secret",
+	Usage: "generate and rotate secrets for served calendars",
+	Subcommands: []cli.Command{
+		{
+			Name:   "key",
+			Usage:  "print a new feed key",
+			Action: keyAction,
+		},
+		{
+			Name:   "password",
+			Usage:  "print the bcrypt hash of a password read from stdin, for the users of a calendar",
+			Flags:  []cli.Flag{flagCost},
+			Action: passwordAction,
+		},
+		{
+			Name:      "rotate",
+			Usage:     "add a new feed key to a calendar in the config file and print it",
+			ArgsUsage: "<calendar id>",
+			Flags:     []cli.Flag{flagConfigFile, flagKeep},
+			Action:    rotateAction,
+		},
+	},
+}
+
+var flagCost = cli.IntFlag{
+	Name:  "cost",
+	Usage: "the bcrypt cost",
+	Value: bcrypt.DefaultCost,
+}
+
+var flagConfigFile = cli.StringFlag{
+	Name:      "config",
+	Usage:     "the config file of the serve command",
+	Value:     "config.yml",
+	EnvVar:    "CONFIG_FILE",
+	TakesFile: true,
+}
+
+var flagKeep = cli.IntFlag{
+	Name:  "keep",
+	Usage: "how many of the newest keys are kept, including the new key, 0 keeps all keys",
+	Value: 2, //nolint: gomnd // the new and the previous key
+}
+
+// newKey returns a random url safe key.
+func newKey() (string, error) {
+	buf := make([]byte, keySize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.Wrap(err, "unable to read random bytes")
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func keyAction(c *cli.Context) error {
+	key, err := newKey()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.App.Writer, key)
+	return nil
+}
+
+func passwordAction(c *cli.Context) error {
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return errors.Wrap(err, "unable to read password")
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return errors.New("password is empty")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), c.Int(flagCost.Name))
+	if err != nil {
+		return errors.Wrap(err, "unable to hash password")
+	}
+	fmt.Fprintln(c.App.Writer, string(hash))
+	return nil
+}
+
+func rotateAction(c *cli.Context) error {
+	id := c.Args().First()
+	if id == "" {
+		return errors.New("calendar id is missing")
+	}
+	key, err := newKey()
+	if err != nil {
+		return err
+	}
+
+	configFile := c.String(flagConfigFile.Name)
+	stat, err := os.Stat(configFile)
+	if err != nil {
+		return errors.Wrapf(err, "unable to stat `%s'", configFile)
+	}
+	buf, err := os.ReadFile(configFile)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read `%s'", configFile)
+	}
+	buf, err = rotateKey(buf, id, key, c.Int(flagKeep.Name))
+	if err != nil {
+		return err
+	}
+
+	// replace the file atomically, so serve never reloads a partial config
+	tmp, err := os.CreateTemp(filepath.Dir(configFile), filepath.Base(configFile)+".*")
+	if err != nil {
+		return errors.Wrap(err, "unable to create temporary file")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "unable to write `%s'", tmp.Name())
+	}
+	if err := tmp.Chmod(stat.Mode().Perm()); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "unable to chmod `%s'", tmp.Name())
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "unable to close `%s'", tmp.Name())
+	}
+	if err := os.Rename(tmp.Name(), configFile); err != nil {
+		return errors.Wrapf(err, "unable to rename `%s' to `%s'", tmp.Name(), configFile)
+	}
+
+	fmt.Fprintln(c.App.Writer, key)
+	return nil
+}
+
+// rotateKey adds key as the first entry of access.keys of the calendar in the config
+// and drops the oldest keys, so only keep keys remain.
+func rotateKey(config []byte, id, key string, keep int) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(config, &doc); err != nil {
+		return nil, errors.Wrap(err, "unable to decode config")
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("config is not a map of calendars")
+	}
+	calendar := mappingValue(doc.Content[0], id)
+	if calendar == nil || calendar.Kind != yaml.MappingNode {
+		return nil, errors.Errorf("calendar `%s' does not exist", id)
+	}
+	access := mappingValue(calendar, "access")
+	switch {
+	case access == nil:
+		access = addMappingValue(calendar, "access", &yaml.Node{Kind: yaml.MappingNode})
+	case isNull(access):
+		*access = yaml.Node{Kind: yaml.MappingNode}
+	case access.Kind != yaml.MappingNode:
+		return nil, errors.Errorf("access of `%s' is not a map", id)
+	}
+	keys := mappingValue(access, "keys")
+	switch {
+	case keys == nil:
+		keys = addMappingValue(access, "keys", &yaml.Node{Kind: yaml.SequenceNode})
+	case isNull(keys):
+		*keys = yaml.Node{Kind: yaml.SequenceNode}
+	case keys.Kind != yaml.SequenceNode:
+		return nil, errors.Errorf("access.keys of `%s' is not a list", id)
+	}
+
+	keys.Content = append([]*yaml.Node{{Kind: yaml.ScalarNode, Value: key}}, keys.Content...)
+	if keep > 0 && len(keys.Content) > keep {
+		keys.Content = keys.Content[:keep]
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2) //nolint: gomnd // indent like the example config
+	if err := enc.Encode(&doc); err != nil {
+		return nil, errors.Wrap(err, "unable to encode config")
+	}
+	if err := enc.Close(); err != nil {
+		return nil, errors.Wrap(err, "unable to encode config")
+	}
+	return buf.Bytes(), nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null"
+}
+
+func addMappingValue(node *yaml.Node, key string, value *yaml.Node) *yaml.Node {
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	return value
+}
@@ -0,0 +1,57 @@
+package secret
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateKey(t *testing.T) {
+	config := []byte(`# my calendars
+first:
+  source: ics
+  path: first.ics
+second:
+  source: ics
+  path: second.ics
+  access:
+    keys:
+      - key-1
+`)
+
+	result, err := rotateKey(config, "first", "key-a", 2)
+	require.NoError(t, err)
+	require.Equal(t, `# my calendars
+first:
+  source: ics
+  path: first.ics
+  access:
+    keys:
+      - key-a
+second:
+  source: ics
+  path: second.ics
+  access:
+    keys:
+      - key-1
+`, string(result))
+
+	result, err = rotateKey(result, "second", "key-2", 2)
+	require.NoError(t, err)
+	result, err = rotateKey(result, "second", "key-3", 2)
+	require.NoError(t, err)
+	require.Contains(t, string(result), `
+  access:
+    keys:
+      - key-3
+      - key-2
+`)
+	require.NotContains(t, string(result), "key-1")
+
+	_, err = rotateKey(config, "third", "key", 2)
+	require.Error(t, err)
+
+	key, err := newKey()
+	require.NoError(t, err)
+	require.Len(t, key, 43)
+}
@@ -0,0 +1,121 @@
+package serve
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"regexp"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minKeyLength is the minimum length of a feed key, so it can not be guessed.
+const minKeyLength = 16
+
+var validKey = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// AccessConfig restricts who can fetch a calendar, a calendar without any setting is public.
+type AccessConfig struct {
+	// Keys grant access if one of them is in the path (/{id}/{key}.{format}) or in the key query parameter.
+	// More than one key can be set, so a new key can be added before the old one is removed.
+	Keys []string `yaml:"keys" json:"keys,omitempty"`
+	// Users maps user names to bcrypt hashed passwords for HTTP Basic auth.
+	Users map[string]string `yaml:"users" json:"users,omitempty"`
+	// AllowedNetworks are the CIDRs or ips of the clients that are allowed to fetch the calendar,
+	// they are checked in addition to the keys and users.
+	AllowedNetworks []string `yaml:"allowed_networks" json:"allowed_networks,omitempty"`
+
+	networks []*net.IPNet
+}
+
+func (a *AccessConfig) validate() error {
+	for _, key := range a.Keys {
+		if len(key) < minKeyLength {
+			return errors.Errorf("keys must be at least %d characters long", minKeyLength)
+		}
+		if !validKey.MatchString(key) {
+			return errors.New("keys may only contain letters, digits, `-' and `_'")
+		}
+	}
+	for user, hash := range a.Users {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return errors.Wrapf(err, "password of `%s' is not a bcrypt hash", user)
+		}
+	}
+	a.networks = nil
+	for _, s := range a.AllowedNetworks {
+		if ip := net.ParseIP(s); ip != nil {
+			if ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse network `%s'", s)
+		}
+		a.networks = append(a.networks, network)
+	}
+	return nil
+}
+
+// authorize returns http.StatusOK if the request may fetch the calendar, otherwise the status to respond with.
+// A missing or wrong key is answered with http.StatusNotFound, so the calendar can not be discovered.
+func (a *AccessConfig) authorize(r *http.Request, key string) int {
+	if len(a.networks) > 0 && !a.allowedClient(r.RemoteAddr) {
+		return http.StatusForbidden
+	}
+	if len(a.Keys) == 0 && len(a.Users) == 0 {
+		return http.StatusOK
+	}
+	if key == "" {
+		key = r.URL.Query().Get("key")
+	}
+	if key != "" && a.validKey(key) {
+		return http.StatusOK
+	}
+	if len(a.Users) == 0 {
+		return http.StatusNotFound
+	}
+	if user, password, ok := r.BasicAuth(); ok && a.validUser(user, password) {
+		return http.StatusOK
+	}
+	return http.StatusUnauthorized
+}
+
+func (a *AccessConfig) allowedClient(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range a.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *AccessConfig) validKey(key string) bool {
+	valid := false
+	for _, k := range a.Keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+func (a *AccessConfig) validUser(user, password string) bool {
+	hash, ok := a.Users[user]
+	if !ok {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,55 @@
+package serve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAccessAuthorize(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	request := func(target, remoteAddr, user, password string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+		r.RemoteAddr = remoteAddr
+		if user != "" {
+			r.SetBasicAuth(user, password)
+		}
+		return r
+	}
+
+	var public AccessConfig
+	require.NoError(t, public.validate())
+	require.Equal(t, http.StatusOK, public.authorize(request("/cal.ics", "192.0.2.1:1234", "", ""), ""))
+
+	keys := AccessConfig{Keys: []string{"old-key-0123456789", "new-key-0123456789"}}
+	require.NoError(t, keys.validate())
+	require.Equal(t, http.StatusNotFound, keys.authorize(request("/cal.ics", "192.0.2.1:1234", "", ""), ""))
+	require.Equal(t, http.StatusNotFound, keys.authorize(request("/cal/wrong-key-0123456789.ics", "192.0.2.1:1234", "", ""), "wrong-key-0123456789"))
+	require.Equal(t, http.StatusOK, keys.authorize(request("/cal/old-key-0123456789.ics", "192.0.2.1:1234", "", ""), "old-key-0123456789"))
+	require.Equal(t, http.StatusOK, keys.authorize(request("/cal.ics?key=new-key-0123456789", "192.0.2.1:1234", "", ""), ""))
+
+	users := AccessConfig{Users: map[string]string{"alice": string(hash)}}
+	require.NoError(t, users.validate())
+	require.Equal(t, http.StatusUnauthorized, users.authorize(request("/cal.ics", "192.0.2.1:1234", "", ""), ""))
+	require.Equal(t, http.StatusUnauthorized, users.authorize(request("/cal.ics", "192.0.2.1:1234", "alice", "wrong"), ""))
+	require.Equal(t, http.StatusUnauthorized, users.authorize(request("/cal.ics", "192.0.2.1:1234", "bob", "secret"), ""))
+	require.Equal(t, http.StatusOK, users.authorize(request("/cal.ics", "192.0.2.1:1234", "alice", "secret"), ""))
+
+	networks := AccessConfig{AllowedNetworks: []string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"}, Keys: []string{"new-key-0123456789"}}
+	require.NoError(t, networks.validate())
+	require.Equal(t, http.StatusForbidden, networks.authorize(request("/cal.ics?key=new-key-0123456789", "192.0.2.2:1234", "", ""), ""))
+	require.Equal(t, http.StatusOK, networks.authorize(request("/cal.ics?key=new-key-0123456789", "192.0.2.1:1234", "", ""), ""))
+	require.Equal(t, http.StatusOK, networks.authorize(request("/cal.ics?key=new-key-0123456789", "10.1.2.3:1234", "", ""), ""))
+	require.Equal(t, http.StatusOK, networks.authorize(request("/cal.ics?key=new-key-0123456789", "[2001:db8::1]:1234", "", ""), ""))
+	require.Equal(t, http.StatusNotFound, networks.authorize(request("/cal.ics", "10.1.2.3:1234", "", ""), ""))
+
+	require.Error(t, (&AccessConfig{Keys: []string{"short"}}).validate())
+	require.Error(t, (&AccessConfig{Keys: []string{"not a valid key with spaces"}}).validate())
+	require.Error(t, (&AccessConfig{Users: map[string]string{"alice": "secret"}}).validate())
+	require.Error(t, (&AccessConfig{AllowedNetworks: []string{"10.0.0.0/33"}}).validate())
+}
@@ -0,0 +1,142 @@
+package serve
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// response is a rendered calendar.
+type response struct {
+	body         []byte
+	contentType  string
+	etag         string
+	lastModified time.Time
+	createdAt    time.Time
+}
+
+// newResponse creates a response that was modified when it was rendered.
+// The update times of the events are not used, because deleted events, another time range or another config
+// do not update any event, the cache keeps the time of the previous response as long as the content does not change.
+func newResponse(body []byte, contentType string) *response {
+	sum := sha256.Sum256(body)
+	now := time.Now()
+	return &response{
+		body:         body,
+		contentType:  contentType,
+		etag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+		lastModified: now,
+		createdAt:    now,
+	}
+}
+
+// serve writes the response, conditional requests with If-None-Match or If-Modified-Since
+// are answered with 304 Not Modified.
+func (resp *response) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", resp.contentType)
+	w.Header().Set("ETag", resp.etag)
+	http.ServeContent(w, r, "", resp.lastModified, bytes.NewReader(resp.body))
+}
+
+type cacheKey struct {
+	id     string
+	format string
+	// view is the key of the requested view, it is empty for the configured view.
+	view string
+}
+
+// responseCache holds the rendered calendars per calendar and format.
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[cacheKey]*response
+	// generations are increased whenever the responses of a calendar are removed.
+	generations map[string]uint64
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		entries:     make(map[cacheKey]*response),
+		generations: make(map[string]uint64),
+	}
+}
+
+// get returns the cached response if it is younger than ttl.
+func (c *responseCache) get(key cacheKey, ttl time.Duration) (*response, bool) {
+	if ttl <= 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp, ok := c.entries[key]
+	if !ok || time.Since(resp.createdAt) >= ttl {
+		return nil, false
+	}
+	return resp, true
+}
+
+// latest returns the cached response regardless of its age.
+func (c *responseCache) latest(key cacheKey) (*response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp, ok := c.entries[key]
+	return resp, ok
+}
+
+// put stores the response, it keeps the modification time of the previous response if the content is the same.
+func (c *responseCache) put(key cacheKey, resp *response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store(key, resp)
+}
+
+// generation returns the current generation of the responses of the calendar.
+func (c *responseCache) generation(id string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generations[id]
+}
+
+// putGeneration stores the response unless the responses of the calendar were removed after gen was taken,
+// e.g. because the config changed while the calendar was rendered with the old one.
+func (c *responseCache) putGeneration(key cacheKey, resp *response, gen uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generations[key.id] != gen {
+		return false
+	}
+	c.store(key, resp)
+	return true
+}
+
+func (c *responseCache) store(key cacheKey, resp *response) {
+	if prev, ok := c.entries[key]; ok && prev.etag == resp.etag {
+		resp.lastModified = prev.lastModified
+	}
+	c.entries[key] = resp
+}
+
+// remove drops the responses of the calendar in every format.
+func (c *responseCache) remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generations[id]++
+	for key := range c.entries {
+		if key.id == id {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// sweepViews drops the responses of requested views of the calendar that are older than ttl.
+func (c *responseCache) sweepViews(id string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, resp := range c.entries {
+		if key.id == id && key.view != "" && time.Since(resp.createdAt) >= ttl {
+			delete(c.entries, key)
+		}
+	}
+}
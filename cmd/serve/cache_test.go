@@ -0,0 +1,106 @@
+package serve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseServe(t *testing.T) {
+	updated := time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC)
+	resp := newResponse([]byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"), "text/calendar; charset=utf-8")
+	require.Equal(t, resp.createdAt, resp.lastModified)
+	resp.lastModified = updated
+
+	do := func(header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/test.ics", http.NoBody)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		resp.serve(w, r)
+		return w
+	}
+
+	w := do(nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n", w.Body.String())
+	require.Equal(t, "text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Equal(t, "Wed, 01 May 2024 10:00:00 GMT", w.Header().Get("Last-Modified"))
+	etag := w.Header().Get("ETag")
+	require.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+
+	w = do(http.Header{"If-None-Match": {etag}})
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Empty(t, w.Body.String())
+
+	w = do(http.Header{"If-None-Match": {`"other"`}})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = do(http.Header{"If-Modified-Since": {"Wed, 01 May 2024 10:00:00 GMT"}})
+	require.Equal(t, http.StatusNotModified, w.Code)
+
+	w = do(http.Header{"If-Modified-Since": {"Wed, 01 May 2024 09:00:00 GMT"}})
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestResponseCache(t *testing.T) {
+	cache := newResponseCache()
+	key := cacheKey{id: "test", format: "ics"}
+
+	_, ok := cache.get(key, time.Minute)
+	require.False(t, ok)
+
+	resp := newResponse([]byte("body"), "text/plain")
+	cache.put(key, resp)
+
+	got, ok := cache.get(key, time.Minute)
+	require.True(t, ok)
+	require.Same(t, resp, got)
+
+	_, ok = cache.get(key, 0)
+	require.False(t, ok)
+
+	resp.createdAt = time.Now().Add(-time.Hour)
+	_, ok = cache.get(key, time.Minute)
+	require.False(t, ok)
+}
+
+func TestResponseCacheLastModified(t *testing.T) {
+	cache := newResponseCache()
+	key := cacheKey{id: "test", format: "ics"}
+
+	first := newResponse([]byte("body"), "text/plain")
+	first.lastModified = time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC)
+	cache.put(key, first)
+
+	// the same content keeps its modification time
+	same := newResponse([]byte("body"), "text/plain")
+	cache.put(key, same)
+	require.Equal(t, first.lastModified, same.lastModified)
+
+	// a deleted event does not update any event, but changes the content
+	changed := newResponse([]byte("other body"), "text/plain")
+	cache.put(key, changed)
+	require.True(t, changed.lastModified.After(first.lastModified))
+}
+
+func TestResponseCacheGeneration(t *testing.T) {
+	cache := newResponseCache()
+	key := cacheKey{id: "test", format: "ics"}
+
+	gen := cache.generation("test")
+	require.True(t, cache.putGeneration(key, newResponse([]byte("body"), "text/plain"), gen))
+
+	// the config changed while the calendar was rendered with the old one
+	stale := cache.generation("test")
+	cache.remove("test")
+	require.False(t, cache.putGeneration(key, newResponse([]byte("stale"), "text/plain"), stale))
+	_, ok := cache.latest(key)
+	require.False(t, ok)
+
+	require.True(t, cache.putGeneration(key, newResponse([]byte("new"), "text/plain"), cache.generation("test")))
+}
@@ -1,6 +1,7 @@
 package serve
 
 import (
+	"net/http"
 	"os"
 	"sync"
 	"time"
@@ -8,22 +9,86 @@ import (
 	"github.com/Eun/gcal-to-ics/pkg/gti"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
-	"github.com/urfave/cli"
 	yaml "gopkg.in/yaml.v3"
 )
 
+const (
+	sourceGoogle = "google"
+	sourceICS    = "ics"
+	sourceMerge  = "merge"
+)
+
+// MergedCalendarConfig is one of the calendars of a calendar with the merge source.
+type MergedCalendarConfig struct {
+	// Source is either google (the default) or ics.
+	Source       string `yaml:"source" json:"source,omitempty"`
+	URL          string `yaml:"url" json:"url,omitempty"`
+	Path         string `yaml:"path" json:"path,omitempty"`
+	AccountEmail string `yaml:"account_email" json:"account_email,omitempty"`
+	CalendarName string `yaml:"calendar_name" json:"calendar_name,omitempty"`
+	// CalendarID selects the calendar by its id instead of the CalendarName.
+	CalendarID string `yaml:"calendar_id" json:"calendar_id,omitempty"`
+	// FreeBusyQuery reads only the busy time of a google calendar with Google's freebusy.query.
+	FreeBusyQuery   bool                `yaml:"freebusy_query" json:"freebusy_query,omitempty"`
+	HideFields      gti.HideFields      `yaml:"hide_fields" json:"hide_fields"`
+	OverwriteFields gti.OverwriteFields `yaml:"overwrite_fields" json:"overwrite_fields"`
+	// Filter selects the events of the calendar that are merged.
+	Filter gti.Filter `yaml:"filter" json:"filter"`
+	// TemplateFields rewrite fields of the events of the calendar with templates.
+	TemplateFields gti.TemplateFields `yaml:"template_fields" json:"template_fields"`
+	// Redact removes sensitive data from the summary, description and location of the events of the calendar.
+	Redact gti.Redaction `yaml:"redact" json:"redact"`
+	// Privacy busy only merges when the calendar is busy, with the BusyLabel.
+	Privacy   string `yaml:"privacy" json:"privacy,omitempty"`
+	BusyLabel string `yaml:"busy_label" json:"busy_label,omitempty"`
+	// SummaryPrefix is prepended to the summary of every event of the calendar.
+	SummaryPrefix string `yaml:"summary_prefix" json:"summary_prefix,omitempty"`
+}
+
 type CalendarConfig struct {
-	AccountEmail    string              `yaml:"account_email" json:"account_email,omitempty"`
-	CalendarName    string              `yaml:"calendar_name" json:"calendar_name,omitempty"`
+	// Source is either google (the default), ics or merge.
+	Source string `yaml:"source" json:"source,omitempty"`
+	// Calendars are merged into one calendar, if Source is merge.
+	// CalendarName is the name of the merged calendar then.
+	Calendars []MergedCalendarConfig `yaml:"calendars" json:"calendars,omitempty"`
+	// URL or Path of the ics file, if Source is ics.
+	URL          string `yaml:"url" json:"url,omitempty"`
+	Path         string `yaml:"path" json:"path,omitempty"`
+	AccountEmail string `yaml:"account_email" json:"account_email,omitempty"`
+	CalendarName string `yaml:"calendar_name" json:"calendar_name,omitempty"`
+	// CalendarID selects the calendar by its id instead of the CalendarName.
+	CalendarID string `yaml:"calendar_id" json:"calendar_id,omitempty"`
+	// FreeBusyQuery reads only the busy time of a google calendar with Google's freebusy.query.
+	FreeBusyQuery   bool                `yaml:"freebusy_query" json:"freebusy_query,omitempty"`
 	Formats         []string            `yaml:"formats" json:"formats,omitempty"`
 	StartFrom       time.Duration       `yaml:"start_from" json:"start_from,omitempty"`
 	EndOn           time.Duration       `yaml:"end_on" json:"end_on,omitempty"`
 	HideFields      gti.HideFields      `yaml:"hide_fields" json:"hide_fields"`
 	OverwriteFields gti.OverwriteFields `yaml:"overwrite_fields" json:"overwrite_fields"`
+	KeepRecurrence  bool                `yaml:"keep_recurrence" json:"keep_recurrence,omitempty"`
+	// Filter selects the events that are served.
+	Filter gti.Filter `yaml:"filter" json:"filter"`
+	// TemplateFields rewrite fields of the events with templates.
+	TemplateFields gti.TemplateFields `yaml:"template_fields" json:"template_fields"`
+	// Redact removes sensitive data from the summary, description and location of the events.
+	Redact gti.Redaction `yaml:"redact" json:"redact"`
+	// Privacy busy only shows when the calendar is busy, with the BusyLabel and times rounded to the BusySlot.
+	Privacy   string        `yaml:"privacy" json:"privacy,omitempty"`
+	BusyLabel string        `yaml:"busy_label" json:"busy_label,omitempty"`
+	BusySlot  time.Duration `yaml:"busy_slot" json:"busy_slot,omitempty"`
+	// CacheTTL overwrites the cache-ttl flag for this calendar.
+	CacheTTL time.Duration `yaml:"cache_ttl" json:"cache_ttl,omitempty"`
+	// RefreshInterval renders the calendar in the background every interval, instead of on request.
+	RefreshInterval time.Duration `yaml:"refresh_interval" json:"refresh_interval,omitempty"`
+	// AllowOverrides allows clients to request another time range or to hide more fields with query parameters.
+	AllowOverrides bool `yaml:"allow_overrides" json:"allow_overrides,omitempty"`
+	// MaxWindow is the longest time range a client can request, it defaults to the configured time range.
+	MaxWindow time.Duration `yaml:"max_window" json:"max_window,omitempty"`
+	// Access restricts who can fetch the calendar.
+	Access AccessConfig `yaml:"access" json:"access"`
 }
 
-func readConfig(c *cli.Context, logger *zerolog.Logger) (*sync.Map, error) {
-	configFile := c.String(flagConfigFile.Name)
+func readConfig(configFile string, logger *zerolog.Logger) (*sync.Map, error) {
 	logger.Debug().Str("config-file", configFile).Msg("reading config")
 	f, err := os.Open(configFile)
 	if err != nil {
@@ -38,11 +103,71 @@ func readConfig(c *cli.Context, logger *zerolog.Logger) (*sync.Map, error) {
 	var r sync.Map
 
 	for id, v := range m {
-		if v.AccountEmail == "" {
-			return nil, errors.New("account_email is missing")
+		if v.Source == sourceMerge {
+			if len(v.Calendars) == 0 {
+				return nil, errors.Errorf("calendars are missing for `%s'", id)
+			}
+			for i := range v.Calendars {
+				mc := &v.Calendars[i]
+				if err := validateSource(&mc.Source, mc.URL, mc.Path, mc.AccountEmail, mc.CalendarName, mc.CalendarID); err != nil {
+					return nil, errors.Wrapf(err, "invalid calendar %d of `%s'", i, id)
+				}
+				if mc.Source == sourceICS {
+					v.KeepRecurrence = true
+				}
+				if mc.FreeBusyQuery && mc.Source != sourceGoogle {
+					return nil, errors.Errorf("freebusy_query of calendar %d of `%s' needs the google source", i, id)
+				}
+				if err := mc.Filter.Validate(); err != nil {
+					return nil, errors.Wrapf(err, "invalid filter of calendar %d of `%s'", i, id)
+				}
+				if err := mc.TemplateFields.Validate(); err != nil {
+					return nil, errors.Wrapf(err, "invalid template_fields of calendar %d of `%s'", i, id)
+				}
+				if err := mc.Redact.Validate(); err != nil {
+					return nil, errors.Wrapf(err, "invalid redact of calendar %d of `%s'", i, id)
+				}
+				switch mc.Privacy {
+				case gti.PrivacyFull, gti.PrivacyBusy:
+				default:
+					return nil, errors.Errorf("privacy `%s' of calendar %d of `%s' is not supported", mc.Privacy, i, id)
+				}
+			}
+		} else if err := validateSource(&v.Source, v.URL, v.Path, v.AccountEmail, v.CalendarName, v.CalendarID); err != nil {
+			return nil, errors.Wrapf(err, "invalid calendar `%s'", id)
+		}
+		if v.FreeBusyQuery && v.Source != sourceGoogle {
+			return nil, errors.Errorf("freebusy_query of `%s' needs the google source", id)
 		}
-		if v.CalendarName == "" {
-			return nil, errors.New("calendar_name is missing")
+		// recurring events of ics files are not expanded, without keep_recurrence only their first instance is kept
+		if v.Source == sourceICS {
+			v.KeepRecurrence = true
+		}
+		for _, format := range v.Formats {
+			if _, ok := gti.LookupFormat(format); !ok {
+				return nil, errors.Errorf("format `%s' of `%s' is not supported", format, id)
+			}
+			// free/busy time can only be computed from expanded events
+			if v.KeepRecurrence && (format == "freebusy" || format == "ifb") {
+				return nil, errors.Errorf("format `%s' of `%s' can not be used with keep_recurrence or ics files", format, id)
+			}
+		}
+		switch v.Privacy {
+		case gti.PrivacyFull, gti.PrivacyBusy:
+		default:
+			return nil, errors.Errorf("privacy `%s' of `%s' is not supported", v.Privacy, id)
+		}
+		if err := v.Filter.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid filter of `%s'", id)
+		}
+		if err := v.TemplateFields.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid template_fields of `%s'", id)
+		}
+		if err := v.Redact.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid redact of `%s'", id)
+		}
+		if err := v.Access.validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid access of `%s'", id)
 		}
 		if v.EndOn == 0 {
 			//nolint: gomnd // default 30 days
@@ -52,3 +177,82 @@ func readConfig(c *cli.Context, logger *zerolog.Logger) (*sync.Map, error) {
 	}
 	return &r, nil
 }
+
+// validateSource checks the settings of the source and sets the default source.
+func validateSource(source *string, url, path, accountEmail, calendarName, calendarID string) error {
+	switch *source {
+	case "", sourceGoogle:
+		*source = sourceGoogle
+		if accountEmail == "" {
+			return errors.New("account_email is missing")
+		}
+		if calendarName == "" && calendarID == "" {
+			return errors.New("calendar_name or calendar_id is missing")
+		}
+	case sourceICS:
+		if (url == "") == (path == "") {
+			return errors.New("either url or path is required")
+		}
+	default:
+		return errors.Errorf("source `%s' is not supported", *source)
+	}
+	return nil
+}
+
+// accounts returns the google accounts the calendar is read with.
+func (c *CalendarConfig) accounts() []string {
+	switch c.Source {
+	case sourceGoogle:
+		return []string{c.AccountEmail}
+	case sourceMerge:
+		var accounts []string
+		seen := make(map[string]bool)
+		for _, mc := range c.Calendars {
+			if mc.Source == sourceGoogle && !seen[mc.AccountEmail] {
+				seen[mc.AccountEmail] = true
+				accounts = append(accounts, mc.AccountEmail)
+			}
+		}
+		return accounts
+	}
+	return nil
+}
+
+// source returns the gti.Source of the calendar, or nil for the google source without freebusy_query.
+// clients are the authenticated clients of the accounts of the calendar.
+func (c *CalendarConfig) source(clients map[string]*http.Client) gti.Source {
+	switch c.Source {
+	case sourceGoogle:
+		if c.FreeBusyQuery {
+			return gti.GoogleFreeBusySource{}
+		}
+	case sourceICS:
+		return gti.ICSSource{URL: c.URL, Path: c.Path}
+	case sourceMerge:
+		s := gti.MergeSource{Calendar: gti.Calendar{Summary: c.CalendarName}}
+		for _, mc := range c.Calendars {
+			config := &gti.Config{
+				AccountEmail:    mc.AccountEmail,
+				CalendarName:    mc.CalendarName,
+				CalendarID:      mc.CalendarID,
+				Client:          clients[mc.AccountEmail],
+				HideFields:      mc.HideFields,
+				OverwriteFields: mc.OverwriteFields,
+				Filter:          mc.Filter,
+				TemplateFields:  mc.TemplateFields,
+				Redaction:       mc.Redact,
+				Privacy:         mc.Privacy,
+				BusyLabel:       mc.BusyLabel,
+			}
+			switch {
+			case mc.Source == sourceICS:
+				config.Source = gti.ICSSource{URL: mc.URL, Path: mc.Path}
+			case mc.FreeBusyQuery:
+				config.Source = gti.GoogleFreeBusySource{}
+			}
+			s.Calendars = append(s.Calendars, gti.MergedCalendar{Config: config, SummaryPrefix: mc.SummaryPrefix})
+		}
+		return s
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package serve
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Eun/gcal-to-ics/pkg/gti"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConfigMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	read := func(s string) (*CalendarConfig, error) {
+		require.NoError(t, os.WriteFile(path, []byte(s), 0o600))
+		logger := zerolog.Nop()
+		m, err := readConfig(path, &logger)
+		if err != nil {
+			return nil, err
+		}
+		v, ok := m.Load("team")
+		require.True(t, ok)
+		calendarConfig := v.(CalendarConfig)
+		return &calendarConfig, nil
+	}
+
+	calendarConfig, err := read(`
+team:
+  source: merge
+  calendar_name: Team
+  formats: [ics]
+  calendars:
+    - account_email: alice@example.com
+      calendar_name: Alice
+      summary_prefix: "[Alice] "
+    - account_email: bob@example.com
+      calendar_name: Bob
+      hide_fields:
+        location: true
+      redact:
+        builtin: [passcodes]
+      privacy: busy
+      busy_label: Away
+    - account_email: alice@example.com
+      calendar_name: Holidays
+    - source: ics
+      path: holidays.ics
+      filter:
+        skip_declined: true
+`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice@example.com", "bob@example.com"}, calendarConfig.accounts())
+
+	source, ok := calendarConfig.source(nil).(gti.MergeSource)
+	require.True(t, ok)
+	require.Equal(t, "Team", source.Calendar.Summary)
+	require.Len(t, source.Calendars, 4)
+	require.Equal(t, "[Alice] ", source.Calendars[0].SummaryPrefix)
+	require.True(t, source.Calendars[1].Config.HideFields.Location)
+	require.Equal(t, []string{"passcodes"}, source.Calendars[1].Config.Redaction.Builtin)
+	require.Equal(t, gti.PrivacyBusy, source.Calendars[1].Config.Privacy)
+	require.Equal(t, "Away", source.Calendars[1].Config.BusyLabel)
+	require.Equal(t, gti.ICSSource{Path: "holidays.ics"}, source.Calendars[3].Config.Source)
+	require.True(t, source.Calendars[3].Config.Filter.SkipDeclined)
+	// the ics calendar can not be expanded
+	require.True(t, calendarConfig.KeepRecurrence)
+
+	_, err = read(`
+team:
+  source: merge
+`)
+	require.Error(t, err)
+
+	_, err = read(`
+team:
+  source: merge
+  calendars:
+    - source: merge
+`)
+	require.Error(t, err)
+
+	_, err = read(`
+team:
+  source: merge
+  calendars:
+    - source: ics
+      path: holidays.ics
+      filter:
+        hours: 18-8
+`)
+	require.EqualError(t, err, "invalid filter of calendar 0 of `team': invalid hours `18-8'")
+
+	_, err = read(`
+team:
+  source: merge
+  calendars:
+    - source: ics
+      path: holidays.ics
+      privacy: hidden
+`)
+	require.EqualError(t, err, "privacy `hidden' of calendar 0 of `team' is not supported")
+
+	_, err = read(`
+team:
+  account_email: alice@example.com
+  calendar_name: Team
+  redact:
+    builtin: [emails, iban]
+`)
+	require.EqualError(t, err, "invalid redact of `team': unknown built-in redaction `iban'")
+}
+
+func TestReadConfigFreeBusy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	read := func(s string) (*CalendarConfig, error) {
+		require.NoError(t, os.WriteFile(path, []byte(s), 0o600))
+		logger := zerolog.Nop()
+		m, err := readConfig(path, &logger)
+		if err != nil {
+			return nil, err
+		}
+		v, ok := m.Load("colleague")
+		require.True(t, ok)
+		calendarConfig := v.(CalendarConfig)
+		return &calendarConfig, nil
+	}
+
+	calendarConfig, err := read(`
+colleague:
+  account_email: me@example.com
+  calendar_id: colleague@example.com
+  freebusy_query: true
+  formats: [ifb, ics]
+`)
+	require.NoError(t, err)
+	require.Equal(t, gti.GoogleFreeBusySource{}, calendarConfig.source(nil))
+
+	_, err = read(`
+colleague:
+  account_email: me@example.com
+  calendar_id: colleague@example.com
+  keep_recurrence: true
+  formats: [ifb]
+`)
+	require.EqualError(t, err, "format `ifb' of `colleague' can not be used with keep_recurrence or ics files")
+
+	_, err = read(`
+colleague:
+  source: ics
+  path: colleague.ics
+  freebusy_query: true
+`)
+	require.EqualError(t, err, "freebusy_query of `colleague' needs the google source")
+}
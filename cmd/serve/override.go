@@ -0,0 +1,141 @@
+package serve
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Eun/gcal-to-ics/pkg/gti"
+	"github.com/pkg/errors"
+)
+
+const overrideDateFormat = "2006-01-02"
+
+// overrideParameters are the query parameters a client can use to request another view of a calendar.
+var overrideParameters = []string{"start", "end", "days", "hide"}
+
+// view is a time range and the hidden fields requested with query parameters.
+type view struct {
+	// key identifies the view in the cache, it is empty for the configured view.
+	key        string
+	startFrom  time.Time
+	endOn      time.Time
+	hideFields gti.HideFields
+}
+
+// parseView returns the view requested with the query parameters start, end, days and hide.
+// start and end are dates (the end date is included) or RFC 3339 times, days is the number of days
+// after start. The range may not exceed max_window, or the configured range if max_window is not set.
+func parseView(query url.Values, calendarConfig *CalendarConfig, now time.Time) (*view, error) {
+	v := &view{
+		startFrom:  now.Add(-calendarConfig.StartFrom),
+		endOn:      now.Add(calendarConfig.EndOn),
+		hideFields: calendarConfig.HideFields,
+	}
+
+	var keys []string
+	for _, name := range overrideParameters {
+		if value := query.Get(name); value != "" {
+			keys = append(keys, name+"="+value)
+		}
+	}
+	if len(keys) == 0 {
+		return v, nil
+	}
+	if !calendarConfig.AllowOverrides {
+		return nil, errors.New("overrides are not allowed for this calendar")
+	}
+	v.key = strings.Join(keys, "&")
+
+	if s := query.Get("start"); s != "" {
+		t, err := parseOverrideTime(s, false)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid start")
+		}
+		v.startFrom = t
+	}
+	if s := query.Get("end"); s != "" {
+		t, err := parseOverrideTime(s, true)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid end")
+		}
+		v.endOn = t
+	}
+	if s := query.Get("days"); s != "" {
+		if query.Get("end") != "" {
+			return nil, errors.New("days and end can not be used together")
+		}
+		days, err := strconv.Atoi(s)
+		if err != nil || days < 1 {
+			return nil, errors.Errorf("invalid days `%s'", s)
+		}
+		if query.Get("start") == "" {
+			v.startFrom = now
+		}
+		v.endOn = v.startFrom.AddDate(0, 0, days)
+	}
+	if !v.endOn.After(v.startFrom) {
+		return nil, errors.New("end must be after start")
+	}
+	maxWindow := calendarConfig.MaxWindow
+	if maxWindow == 0 {
+		maxWindow = calendarConfig.StartFrom + calendarConfig.EndOn
+	}
+	if v.endOn.Sub(v.startFrom) > maxWindow {
+		return nil, errors.Errorf("the time range may not be longer than %s", maxWindow)
+	}
+
+	if s := query.Get("hide"); s != "" {
+		for _, name := range strings.Split(s, ",") {
+			if err := hideField(&v.hideFields, strings.TrimSpace(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+// parseOverrideTime parses a date or a RFC 3339 time, end dates are moved to the end of the day.
+func parseOverrideTime(s string, end bool) (time.Time, error) {
+	if t, err := time.Parse(overrideDateFormat, s); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.Errorf("`%s' is neither a date nor a RFC 3339 time", s)
+	}
+	return t, nil
+}
+
+// hideField hides the field with the yaml name of the gti.HideFields, fields can not be unhidden.
+func hideField(fields *gti.HideFields, name string) error {
+	switch name {
+	case "uid":
+		fields.UID = true
+	case "organizer":
+		fields.Organizer = true
+	case "attendees":
+		fields.Attendees = true
+	case "visibility":
+		fields.Visibility = true
+	case "description":
+		fields.Description = true
+	case "location":
+		fields.Location = true
+	case "conference":
+		fields.Conference = true
+	case "transparency":
+		fields.Transparency = true
+	case "status":
+		fields.Status = true
+	case "reminders":
+		fields.Reminders = true
+	default:
+		return errors.Errorf("unknown field `%s'", name)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package serve
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Eun/gcal-to-ics/pkg/gti"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseView(t *testing.T) {
+	now := time.Date(2026, time.January, 10, 12, 0, 0, 0, time.UTC)
+	calendarConfig := &CalendarConfig{
+		StartFrom:      24 * time.Hour,
+		EndOn:          30 * 24 * time.Hour,
+		HideFields:     gti.HideFields{Organizer: true},
+		AllowOverrides: true,
+		MaxWindow:      100 * 24 * time.Hour,
+	}
+	parse := func(query string) (*view, error) {
+		values, err := url.ParseQuery(query)
+		require.NoError(t, err)
+		return parseView(values, calendarConfig, now)
+	}
+
+	v, err := parse("key=secret-key-0123456789")
+	require.NoError(t, err)
+	require.Empty(t, v.key)
+	require.Equal(t, now.Add(-24*time.Hour), v.startFrom)
+	require.Equal(t, now.Add(30*24*time.Hour), v.endOn)
+
+	v, err = parse("start=2026-01-01&end=2026-03-31")
+	require.NoError(t, err)
+	require.Equal(t, "start=2026-01-01&end=2026-03-31", v.key)
+	require.Equal(t, time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), v.startFrom)
+	require.Equal(t, time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC), v.endOn)
+
+	v, err = parse("days=7")
+	require.NoError(t, err)
+	require.Equal(t, now, v.startFrom)
+	require.Equal(t, now.AddDate(0, 0, 7), v.endOn)
+
+	v, err = parse("start=2026-02-01T10:00:00Z&days=1")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2026, time.February, 2, 10, 0, 0, 0, time.UTC), v.endOn)
+
+	v, err = parse("hide=attendees,location")
+	require.NoError(t, err)
+	require.Equal(t, gti.HideFields{Organizer: true, Attendees: true, Location: true}, v.hideFields)
+
+	for _, query := range []string{
+		"start=2026-01-01&end=2026-12-31",
+		"start=2026-02-01&end=2026-01-01",
+		"days=0",
+		"days=7&end=2026-03-31",
+		"start=tomorrow",
+		"hide=summary",
+	} {
+		_, err = parse(query)
+		require.Error(t, err, query)
+	}
+
+	// without max_window the configured range is the maximum
+	calendarConfig.MaxWindow = 0
+	_, err = parse("days=31")
+	require.NoError(t, err)
+	_, err = parse("days=32")
+	require.Error(t, err)
+
+	calendarConfig.AllowOverrides = false
+	_, err = parse("days=7")
+	require.Error(t, err)
+}
@@ -0,0 +1,220 @@
+package serve
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Eun/gcal-to-ics/pkg/gti"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// renderCalendar fetches the calendar once and renders it in every format.
+func renderCalendar(ctx context.Context, config *gti.Config, formats []string) (map[string]*response, error) {
+	cal, events, err := gti.Fetch(ctx, config)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch calendar")
+	}
+
+	responses := make(map[string]*response, len(formats))
+	for _, format := range formats {
+		var buf bytes.Buffer
+		cfg := *config
+		cfg.Format = format
+		cfg.Writer = &buf
+		if err := gti.Write(&cfg, cal, events); err != nil {
+			return nil, errors.Wrapf(err, "unable to write %s", format)
+		}
+		responses[format] = newResponse(buf.Bytes(), gti.ContentType(format))
+	}
+	return responses, nil
+}
+
+// errNoToken is returned by a refresh if an account of the calendar was not authorized yet.
+var errNoToken = errors.New("no token available")
+
+// refreshStatus is the state of a calendar that is refreshed in the background.
+type refreshStatus struct {
+	ID              string     `json:"id"`
+	RefreshInterval string     `json:"refresh_interval"`
+	LastRefresh     *time.Time `json:"last_refresh,omitempty"`
+	LastSuccess     *time.Time `json:"last_success,omitempty"`
+	// LastError is the kind of the last error, the error itself is only logged as it can contain account emails.
+	LastError   string     `json:"last_error,omitempty"`
+	NextRefresh *time.Time `json:"next_refresh,omitempty"`
+}
+
+type refreshJob struct {
+	// ctx is canceled when the calendar is removed from the scheduler.
+	ctx  context.Context
+	id   string
+	done chan struct{}
+}
+
+// scheduler renders the calendars with a refresh interval in the background,
+// so the handler can serve the last good snapshot.
+type scheduler struct {
+	logger *zerolog.Logger
+	// refresh renders the calendar with the id and stores the result.
+	refresh func(ctx context.Context, id string) error
+	timeout time.Duration
+	jobs    chan refreshJob
+
+	mu       sync.Mutex
+	calendar map[string]*scheduledCalendar
+}
+
+type scheduledCalendar struct {
+	status   refreshStatus
+	interval time.Duration
+	cancel   context.CancelFunc
+}
+
+func newScheduler(logger *zerolog.Logger, workers int, timeout time.Duration,
+	refresh func(ctx context.Context, id string) error,
+) *scheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	s := &scheduler{
+		logger:   logger,
+		refresh:  refresh,
+		timeout:  timeout,
+		jobs:     make(chan refreshJob),
+		calendar: make(map[string]*scheduledCalendar),
+	}
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	return s
+}
+
+func (s *scheduler) work() {
+	for job := range s.jobs {
+		s.run(job.ctx, job.id)
+		close(job.done)
+	}
+}
+
+func (s *scheduler) run(ctx context.Context, id string) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := s.refresh(ctx, id)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, ok := s.calendar[id]
+	if !ok || ctx.Err() == context.Canceled {
+		return
+	}
+	sc.status.LastRefresh = &start
+	if err != nil {
+		sc.status.LastError = statusError(err)
+		s.logger.Error().Err(err).Str("id", id).Msg("refresh failed, serving the last snapshot")
+		return
+	}
+	sc.status.LastSuccess = &start
+	sc.status.LastError = ""
+	s.logger.Debug().Str("id", id).Dur("took", time.Since(start)).Msg("refreshed calendar")
+}
+
+// statusError describes err without any details of the calendar.
+func statusError(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, errNoToken):
+		return "not authorized"
+	default:
+		return "refresh failed"
+	}
+}
+
+// schedule refreshes the calendar every interval, a running schedule for the same id is replaced.
+func (s *scheduler) schedule(id string, interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sc, ok := s.calendar[id]; ok {
+		if sc.interval == interval {
+			return
+		}
+		sc.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sc := &scheduledCalendar{
+		status:   refreshStatus{ID: id, RefreshInterval: interval.String()},
+		interval: interval,
+		cancel:   cancel,
+	}
+	s.calendar[id] = sc
+	go s.loop(ctx, sc, interval)
+}
+
+// remove stops refreshing the calendar and cancels a refresh that is running.
+func (s *scheduler) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sc, ok := s.calendar[id]; ok {
+		sc.cancel()
+		delete(s.calendar, id)
+	}
+}
+
+func (s *scheduler) loop(ctx context.Context, sc *scheduledCalendar, interval time.Duration) {
+	// spread the first refresh, so not all calendars are fetched at once
+	delay := jitter(interval / 10) //nolint: gomnd // up to 10% of the interval
+	for {
+		next := time.Now().Add(delay)
+		s.mu.Lock()
+		sc.status.NextRefresh = &next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		job := refreshJob{ctx: ctx, id: sc.status.ID, done: make(chan struct{})}
+		select {
+		case <-ctx.Done():
+			return
+		case s.jobs <- job:
+		}
+		<-job.done
+
+		delay = interval + jitter(interval/5) - interval/10 //nolint: gomnd // +-10% of the interval
+	}
+}
+
+// jitter returns a random duration in [0, max).
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	//nolint: gosec // no need for a secure random number
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// statuses returns the state of all scheduled calendars, sorted by id.
+func (s *scheduler) statuses() []refreshStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]refreshStatus, 0, len(s.calendar))
+	for _, sc := range s.calendar {
+		result = append(result, sc.status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
@@ -0,0 +1,104 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Eun/gcal-to-ics/pkg/gti"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler(t *testing.T) {
+	logger := zerolog.Nop()
+
+	var calls int32
+	s := newScheduler(&logger, 2, time.Second, func(ctx context.Context, id string) error {
+		if atomic.AddInt32(&calls, 1)%2 == 0 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	s.schedule("test", 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&calls) >= 2
+	}, time.Second, time.Millisecond)
+
+	statuses := s.statuses()
+	require.Len(t, statuses, 1)
+	require.Equal(t, "test", statuses[0].ID)
+	require.Equal(t, "10ms", statuses[0].RefreshInterval)
+	require.NotNil(t, statuses[0].LastRefresh)
+	require.NotNil(t, statuses[0].LastSuccess)
+	require.NotNil(t, statuses[0].NextRefresh)
+
+	s.remove("test")
+	require.Empty(t, s.statuses())
+	n := atomic.LoadInt32(&calls)
+	time.Sleep(50 * time.Millisecond)
+	// at most one refresh that was already running
+	require.LessOrEqual(t, atomic.LoadInt32(&calls), n+1)
+}
+
+func TestSchedulerRemoveCancels(t *testing.T) {
+	logger := zerolog.Nop()
+
+	started := make(chan struct{})
+	canceled := make(chan error, 1)
+	s := newScheduler(&logger, 1, time.Minute, func(ctx context.Context, id string) error {
+		close(started)
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return ctx.Err()
+	})
+
+	s.schedule("test", 10*time.Millisecond)
+	<-started
+	s.remove("test")
+	select {
+	case err := <-canceled:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		require.Fail(t, "refresh was not canceled")
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	require.Equal(t, "timeout", statusError(pkgerrors.Wrap(context.DeadlineExceeded, "unable to fetch calendar")))
+	require.Equal(t, "not authorized", statusError(pkgerrors.Wrapf(errNoToken, "account `%s'", "me@example.com")))
+	require.Equal(t, "refresh failed", statusError(errors.New("unable to find calendar `me@example.com'")))
+}
+
+func TestRenderCalendar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calendar.ics")
+	require.NoError(t, os.WriteFile(path, []byte("BEGIN:VCALENDAR\r\n"+
+		"VERSION:2.0\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"UID:1@example.com\r\n"+
+		"DTSTART:20240501T100000Z\r\n"+
+		"DTEND:20240501T110000Z\r\n"+
+		"SUMMARY:Meeting\r\n"+
+		"LAST-MODIFIED:20240401T080000Z\r\n"+
+		"END:VEVENT\r\n"+
+		"END:VCALENDAR\r\n"), 0o600))
+
+	logger := zerolog.Nop()
+	responses, err := renderCalendar(context.Background(), &gti.Config{
+		Logger: &logger,
+		Source: gti.ICSSource{Path: path},
+	}, []string{"ics", "jcal"})
+	require.NoError(t, err)
+	require.Len(t, responses, 2)
+	require.Equal(t, "text/calendar; charset=utf-8", responses["ics"].contentType)
+	require.Contains(t, string(responses["ics"].body), "SUMMARY:Meeting\r\n")
+	require.Equal(t, "application/calendar+json; charset=utf-8", responses["jcal"].contentType)
+	require.Equal(t, responses["jcal"].createdAt, responses["jcal"].lastModified)
+	require.NotEqual(t, responses["ics"].etag, responses["jcal"].etag)
+}
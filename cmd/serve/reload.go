@@ -0,0 +1,157 @@
+package serve
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// configChanges are the calendar ids that differ between two configs.
+type configChanges struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+func (c configChanges) empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Changed) == 0
+}
+
+// diffConfig returns the calendar ids that were added, removed or changed from old to new.
+func diffConfig(old, new *sync.Map) configChanges {
+	var changes configChanges
+	new.Range(func(key, value interface{}) bool {
+		id := key.(string)
+		prev, ok := old.Load(id)
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, id)
+		case !reflect.DeepEqual(prev, value):
+			changes.Changed = append(changes.Changed, id)
+		}
+		return true
+	})
+	old.Range(func(key, _ interface{}) bool {
+		if _, ok := new.Load(key); !ok {
+			changes.Removed = append(changes.Removed, key.(string))
+		}
+		return true
+	})
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Changed)
+	return changes
+}
+
+// configReloader holds the current config and replaces it when the config file changes.
+// A config that can not be read or is invalid is logged and the old config is kept.
+type configReloader struct {
+	path   string
+	logger *zerolog.Logger
+	// apply is called after a new config was swapped in.
+	apply func(cfg *sync.Map, changes configChanges)
+
+	current atomic.Pointer[sync.Map]
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+func newConfigReloader(path string, logger *zerolog.Logger, apply func(cfg *sync.Map, changes configChanges)) (*configReloader, error) {
+	r := &configReloader{
+		path:   path,
+		logger: logger,
+		apply:  apply,
+	}
+	r.modTime, r.size = r.stat()
+	cfg, err := readConfig(path, logger)
+	if err != nil {
+		return nil, err
+	}
+	r.current.Store(cfg)
+	return r, nil
+}
+
+// config returns the current config.
+func (r *configReloader) config() *sync.Map {
+	return r.current.Load()
+}
+
+func (r *configReloader) stat() (time.Time, int64) {
+	stat, err := os.Stat(r.path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return stat.ModTime(), stat.Size()
+}
+
+// reload reads the config file and swaps it in if it is valid.
+func (r *configReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.modTime, r.size = r.stat()
+
+	cfg, err := readConfig(r.path, r.logger)
+	if err != nil {
+		r.logger.Error().Err(err).Str("config-file", r.path).Msg("unable to reload config, keeping the old config")
+		return err
+	}
+	changes := diffConfig(r.current.Load(), cfg)
+	r.current.Store(cfg)
+	if changes.empty() {
+		r.logger.Debug().Str("config-file", r.path).Msg("config did not change")
+		return nil
+	}
+	r.logger.Info().
+		Str("config-file", r.path).
+		Strs("added", changes.Added).
+		Strs("removed", changes.Removed).
+		Strs("changed", changes.Changed).
+		Msg("reloaded config")
+	if r.apply != nil {
+		r.apply(cfg, changes)
+	}
+	return nil
+}
+
+// watch reloads the config on SIGHUP, and if interval is greater than 0 whenever
+// the modification time or size of the file changes, until ctx is done.
+func (r *configReloader) watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.logger.Debug().Msg("received SIGHUP, reloading config")
+			_ = r.reload()
+		case <-tick:
+			modTime, size := r.stat()
+			r.mu.Lock()
+			changed := !modTime.Equal(r.modTime) || size != r.size
+			r.mu.Unlock()
+			if changed {
+				_ = r.reload()
+			}
+		}
+	}
+}
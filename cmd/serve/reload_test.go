@@ -0,0 +1,85 @@
+package serve
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	write := func(s string) {
+		require.NoError(t, os.WriteFile(path, []byte(s), 0o600))
+	}
+	write(`
+first:
+  source: ics
+  path: first.ics
+  formats: [ics]
+second:
+  source: ics
+  path: second.ics
+  formats: [ics]
+`)
+
+	logger := zerolog.Nop()
+	applied := make(chan configChanges, 1)
+	reloader, err := newConfigReloader(path, &logger, func(_ *sync.Map, changes configChanges) {
+		applied <- changes
+	})
+	require.NoError(t, err)
+	_, ok := reloader.config().Load("first")
+	require.True(t, ok)
+
+	write(`
+first:
+  source: ics
+  path: first.ics
+  formats: [ics, jcal]
+third:
+  source: ics
+  path: third.ics
+  formats: [ics]
+`)
+	require.NoError(t, reloader.reload())
+	require.Equal(t, configChanges{
+		Added:   []string{"third"},
+		Removed: []string{"second"},
+		Changed: []string{"first"},
+	}, <-applied)
+	v, ok := reloader.config().Load("first")
+	require.True(t, ok)
+	require.Equal(t, []string{"ics", "jcal"}, v.(CalendarConfig).Formats)
+
+	// an invalid config keeps the old one
+	write(`
+first:
+  source: ftp
+`)
+	require.Error(t, reloader.reload())
+	_, ok = reloader.config().Load("third")
+	require.True(t, ok)
+
+	// the watcher picks up changes of the file
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.watch(ctx, 10*time.Millisecond)
+	write(`
+third:
+  source: ics
+  path: third.ics
+  formats: [ics]
+`)
+	select {
+	case changes := <-applied:
+		require.Equal(t, []string{"first"}, changes.Removed)
+	case <-time.After(time.Second):
+		t.Fatal("config was not reloaded")
+	}
+}
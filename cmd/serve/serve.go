@@ -1,8 +1,8 @@
 package serve
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -30,10 +30,17 @@ var Command = cli.Command{
 	Usage:   "serve calendar files",
 	Flags: []cli.Flag{
 		flagConfigFile,
+		flagConfigReloadInterval,
 		flagBindAddress,
 		flagPublicURI,
 		flagTokenDir,
 		flagCryptSecret,
+		flagCacheTTL,
+		flagRefreshWorkers,
+		flagRefreshTimeout,
+		flagStoreDir,
+		flagRealIP,
+		flagStatus,
 	},
 	Action: action,
 }
@@ -46,6 +53,13 @@ var flagConfigFile = cli.StringFlag{
 	TakesFile: true,
 }
 
+var flagConfigReloadInterval = cli.DurationFlag{
+	Name:   "config-reload-interval",
+	Usage:  "how often the config file is checked for changes, 0 only reloads it on SIGHUP",
+	Value:  10 * time.Second, //nolint: gomnd // default reload interval
+	EnvVar: "CONFIG_RELOAD_INTERVAL",
+}
+
 var flagBindAddress = cli.StringFlag{
 	Name:   "bind-address",
 	Usage:  "bind to this address",
@@ -74,6 +88,45 @@ var flagCryptSecret = cli.StringFlag{
 	EnvVar: "CRYPT_SECRET",
 }
 
+var flagCacheTTL = cli.DurationFlag{
+	Name:   "cache-ttl",
+	Usage:  "how long rendered calendars are cached, 0 disables the cache",
+	Value:  5 * time.Minute, //nolint: gomnd // default cache ttl
+	EnvVar: "CACHE_TTL",
+}
+
+var flagRefreshWorkers = cli.IntFlag{
+	Name:   "refresh-workers",
+	Usage:  "how many calendars are refreshed in the background at the same time",
+	Value:  4, //nolint: gomnd // default workers
+	EnvVar: "REFRESH_WORKERS",
+}
+
+var flagRefreshTimeout = cli.DurationFlag{
+	Name:   "refresh-timeout",
+	Usage:  "how long a background refresh or an on-demand rendering of a calendar may take",
+	Value:  5 * time.Minute, //nolint: gomnd // default timeout
+	EnvVar: "REFRESH_TIMEOUT",
+}
+
+var flagStoreDir = cli.StringFlag{
+	Name:   "store-dir",
+	Usage:  "store the events in this directory, so refreshes only fetch the changes",
+	EnvVar: "STORE_DIR",
+}
+
+var flagRealIP = cli.BoolFlag{
+	Name:   "real-ip",
+	Usage:  "use the client ip of the X-Real-IP or X-Forwarded-For header for allowed_networks, only enable it behind a proxy",
+	EnvVar: "REAL_IP",
+}
+
+var flagStatus = cli.BoolFlag{
+	Name:   "status",
+	Usage:  "serve the state of the background refreshes on /status, it is public so only enable it in trusted networks",
+	EnvVar: "STATUS",
+}
+
 func action(c *cli.Context) error {
 	logger := log.With().Str("name", c.Command.Name).Logger()
 
@@ -86,16 +139,125 @@ func action(c *cli.Context) error {
 		return errors.Errorf("`%s' is not a directory", tokenDir)
 	}
 
-	cfgMap, err := readConfig(c, &logger)
+	redirectURL, err := url.JoinPath(c.String(flagPublicURI.Name), "auth")
 	if err != nil {
-		return errors.Wrap(err, "unable to read config")
+		return errors.Wrap(err, "unable to join path")
 	}
 
-	redirectURL, err := url.JoinPath(c.String(flagPublicURI.Name), "auth")
+	cache := newResponseCache()
+
+	var refreshScheduler *scheduler
+	// scheduleRefresh starts, updates or stops the background refresh of the calendar.
+	scheduleRefresh := func(cfgMap *sync.Map, id string) {
+		v, ok := cfgMap.Load(id)
+		if !ok || v.(CalendarConfig).RefreshInterval <= 0 {
+			refreshScheduler.remove(id)
+			return
+		}
+		refreshScheduler.schedule(id, v.(CalendarConfig).RefreshInterval)
+	}
+
+	reloader, err := newConfigReloader(c.String(flagConfigFile.Name), &logger, func(cfgMap *sync.Map, changes configChanges) {
+		for _, id := range changes.Removed {
+			refreshScheduler.remove(id)
+			cache.remove(id)
+		}
+		for _, id := range changes.Changed {
+			// the snapshot was rendered with the old config, so drop it and restart the refresh,
+			// a refresh that is still running with the old config is canceled and its result is discarded
+			refreshScheduler.remove(id)
+			cache.remove(id)
+			scheduleRefresh(cfgMap, id)
+		}
+		for _, id := range changes.Added {
+			scheduleRefresh(cfgMap, id)
+		}
+	})
 	if err != nil {
-		return errors.Wrap(err, "unable to join path")
+		return errors.Wrap(err, "unable to read config")
+	}
+
+	newOauthConfig := func() *oauth2.Config {
+		return createOauthConfig(redirectURL, c.GlobalString("client_id"), c.GlobalString("client_secret"))
+	}
+
+	// newClients returns the authenticated clients for the google accounts of the calendar,
+	// if an account has no token yet it is returned as missing.
+	newClients := func(calendarConfig *CalendarConfig) (clients map[string]*http.Client, missing string, err error) {
+		clients = make(map[string]*http.Client)
+		for _, account := range calendarConfig.accounts() {
+			tokenFile := filepath.Join(tokenDir, hashAccount(account))
+			client, err := getAuthenticatedClient(&logger, c.String(flagCryptSecret.Name), tokenFile, newOauthConfig())
+			if err != nil {
+				return nil, "", err
+			}
+			if client == nil {
+				return nil, account, nil
+			}
+			clients[account] = client
+		}
+		return clients, "", nil
+	}
+
+	newConfig := func(calendarConfig *CalendarConfig, clients map[string]*http.Client) *gti.Config {
+		return &gti.Config{
+			AccountEmail:    calendarConfig.AccountEmail,
+			Logger:          &logger,
+			StartFrom:       time.Now().Add(-calendarConfig.StartFrom),
+			EndOn:           time.Now().Add(calendarConfig.EndOn),
+			CalendarName:    calendarConfig.CalendarName,
+			CalendarID:      calendarConfig.CalendarID,
+			Client:          clients[calendarConfig.AccountEmail],
+			Version:         c.App.Version,
+			HideFields:      calendarConfig.HideFields,
+			OverwriteFields: calendarConfig.OverwriteFields,
+			Privacy:         calendarConfig.Privacy,
+			BusyLabel:       calendarConfig.BusyLabel,
+			BusySlot:        calendarConfig.BusySlot,
+			KeepRecurrence:  calendarConfig.KeepRecurrence,
+			Filter:          calendarConfig.Filter,
+			TemplateFields:  calendarConfig.TemplateFields,
+			Redaction:       calendarConfig.Redact,
+			Source:          calendarConfig.source(clients),
+			StoreDir:        c.String(flagStoreDir.Name),
+		}
 	}
 
+	refresh := func(ctx context.Context, id string) error {
+		// taken before the config is loaded, so a render with a config that is replaced meanwhile is not stored
+		gen := cache.generation(id)
+		v, ok := reloader.config().Load(id)
+		if !ok {
+			return errors.Errorf("no such calendar `%s'", id)
+		}
+		calendarConfig := v.(CalendarConfig)
+		clients, missing, err := newClients(&calendarConfig)
+		if err != nil {
+			return errors.Wrap(err, "unable to get authenticated client")
+		}
+		if missing != "" {
+			return errors.Wrapf(errNoToken, "account `%s' has to open the calendar url to authorize", missing)
+		}
+		responses, err := renderCalendar(ctx, newConfig(&calendarConfig, clients), calendarConfig.Formats)
+		if err != nil {
+			return err
+		}
+		for format, resp := range responses {
+			if !cache.putGeneration(cacheKey{id: id, format: format}, resp, gen) {
+				return errors.Errorf("config of `%s' changed during the refresh", id)
+			}
+		}
+		return nil
+	}
+
+	refreshScheduler = newScheduler(&logger, c.Int(flagRefreshWorkers.Name), c.Duration(flagRefreshTimeout.Name), refresh)
+	cfgMap := reloader.config()
+	cfgMap.Range(func(key, _ interface{}) bool {
+		scheduleRefresh(cfgMap, key.(string))
+		return true
+	})
+	go reloader.watch(context.Background(), c.Duration(flagConfigReloadInterval.Name))
+
 	var stateMap sync.Map
 	type stateEntry struct {
 		originalLocation string
@@ -106,6 +268,9 @@ func action(c *cli.Context) error {
 
 	r := chi.NewRouter()
 	r.Use(middleware.Recoverer)
+	if c.Bool(flagRealIP.Name) {
+		r.Use(middleware.RealIP)
+	}
 	r.Get("/auth", func(w http.ResponseWriter, r *http.Request) {
 		state := r.URL.Query().Get("state")
 		if state == "" {
@@ -164,7 +329,13 @@ func action(c *cli.Context) error {
 		http.Redirect(w, r, entry.originalLocation, http.StatusTemporaryRedirect)
 		_, _ = io.WriteString(w, "authorized, you can close this window.")
 	})
-	r.Get("/{id:[a-zA-Z-0-9]+}.{format}", func(w http.ResponseWriter, r *http.Request) {
+	if c.Bool(flagStatus.Name) {
+		r.Get("/status", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(refreshScheduler.statuses())
+		})
+	}
+	serveCalendar := func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
 		format := chi.URLParam(r, "format")
 		if id == "" || format == "" {
@@ -173,7 +344,8 @@ func action(c *cli.Context) error {
 			return
 		}
 
-		cfg, ok := cfgMap.Load(id)
+		gen := cache.generation(id)
+		cfg, ok := reloader.config().Load(id)
 		if !ok {
 			w.WriteHeader(http.StatusNotFound)
 			fmt.Fprint(w, "not found")
@@ -187,6 +359,23 @@ func action(c *cli.Context) error {
 			return
 		}
 
+		switch status := calendarConfig.Access.authorize(r, chi.URLParam(r, "key")); status {
+		case http.StatusOK:
+		case http.StatusUnauthorized:
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+id+`", charset="UTF-8"`)
+			w.WriteHeader(status)
+			fmt.Fprint(w, "unauthorized")
+			return
+		case http.StatusForbidden:
+			w.WriteHeader(status)
+			fmt.Fprint(w, "forbidden")
+			return
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "not found")
+			return
+		}
+
 		wantedFormatIsAllowed := false
 		for _, f := range calendarConfig.Formats {
 			if format == f {
@@ -201,25 +390,54 @@ func action(c *cli.Context) error {
 			return
 		}
 
-		tokenFile := filepath.Join(tokenDir, hashAccount(calendarConfig.AccountEmail))
+		if _, ok := gti.LookupFormat(format); !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "wanted format is not supported")
+			return
+		}
+
+		v, err := parseView(r.URL.Query(), &calendarConfig, time.Now())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, err.Error())
+			return
+		}
 
-		// get the client
-		oauthConfig := createOauthConfig(redirectURL, c.GlobalString("client_id"), c.GlobalString("client_secret"))
+		key := cacheKey{id: id, format: format, view: v.key}
+		ttl := c.Duration(flagCacheTTL.Name)
+		if calendarConfig.CacheTTL != 0 {
+			ttl = calendarConfig.CacheTTL
+		}
+		if resp, ok := cache.get(key, ttl); ok {
+			resp.serve(w, r)
+			return
+		}
+		// calendars that are refreshed in the background are served from the last snapshot,
+		// they are only rendered here until the first refresh succeeded
+		if calendarConfig.RefreshInterval > 0 && v.key == "" {
+			if resp, ok := cache.latest(key); ok {
+				resp.serve(w, r)
+				return
+			}
+		}
 
-		client, err := getAuthenticatedClient(&logger, c.String(flagCryptSecret.Name), tokenFile, oauthConfig)
+		clients, missing, err := newClients(&calendarConfig)
 		if err != nil {
 			logger.Error().Err(err).Msg("unable to get authenticated client")
 			w.WriteHeader(http.StatusInternalServerError)
 			fmt.Fprint(w, "internal server error")
 			return
 		}
-		if client == nil {
-			logger.Debug().Msg("no token available, redirect to authorization")
+		if missing != "" {
+			// calendars with several accounts are authorized one after another,
+			// the redirect back to the calendar asks for the next missing account
+			logger.Debug().Str("account", missing).Msg("no token available, redirect to authorization")
+			oauthConfig := newOauthConfig()
 			state := uuid.New().String()
 			stateMap.Store(state, &stateEntry{
 				originalLocation: r.RequestURI,
 				oauthConfig:      oauthConfig,
-				accountEmail:     calendarConfig.AccountEmail,
+				accountEmail:     missing,
 				//nolint: gomnd // default timeout is 5 mins
 				validUntil: time.Now().Add(time.Minute * 5),
 			})
@@ -227,21 +445,18 @@ func action(c *cli.Context) error {
 			return
 		}
 
-		var buf bytes.Buffer
-		err = gti.Export(&gti.Config{
-			Format:          format,
-			AccountEmail:    calendarConfig.AccountEmail,
-			Logger:          &logger,
-			StartFrom:       time.Now().Add(-calendarConfig.StartFrom),
-			EndOn:           time.Now().Add(calendarConfig.EndOn),
-			CalendarName:    calendarConfig.CalendarName,
-			Writer:          &buf,
-			Client:          client,
-			Version:         c.App.Version,
-			HideFields:      calendarConfig.HideFields,
-			OverwriteFields: calendarConfig.OverwriteFields,
-		})
-
+		config := newConfig(&calendarConfig, clients)
+		config.StartFrom = v.startFrom
+		config.EndOn = v.endOn
+		config.HideFields = v.hideFields
+		if v.key != "" {
+			// the store keeps the configured time range, a view with another range would replace it
+			config.StoreDir = ""
+		}
+		// the write timeout of the server is based on the refresh timeout, so the rendering has to end before
+		ctx, cancel := context.WithTimeout(r.Context(), c.Duration(flagRefreshTimeout.Name))
+		defer cancel()
+		responses, err := renderCalendar(ctx, config, []string{format})
 		if err != nil {
 			logger.Error().Err(err).Msg("export failed")
 			w.WriteHeader(http.StatusInternalServerError)
@@ -249,20 +464,31 @@ func action(c *cli.Context) error {
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-		_, _ = io.Copy(w, &buf)
-		buf.Reset()
-	})
+		resp := responses[format]
+		switch {
+		case v.key == "":
+			cache.putGeneration(key, resp, gen)
+		case ttl > 0:
+			// views are only cached for the ttl, so arbitrary queries do not fill up the cache
+			cache.sweepViews(id, ttl)
+			cache.putGeneration(key, resp, gen)
+		}
+		resp.serve(w, r)
+	}
+	r.Get("/{id:[a-zA-Z-0-9]+}.{format}", serveCalendar)
+	r.Get("/{id:[a-zA-Z-0-9]+}/{key:[a-zA-Z0-9_-]+}.{format}", serveCalendar)
 	logger.Debug().
 		Str("address", c.String(flagBindAddress.Name)).
 		Str("public_uri", c.String(flagPublicURI.Name)).
 		Msg("listening")
 
+	// calendars that are not refreshed in the background are rendered while the client waits
+	writeTimeout := c.Duration(flagRefreshTimeout.Name) + 10*time.Second //nolint: gomnd // time to write the response
 	server := http.Server{
 		Addr:              c.String(flagBindAddress.Name),
 		Handler:           r,
 		ReadTimeout:       time.Second,
-		WriteTimeout:      time.Second,
+		WriteTimeout:      writeTimeout,
 		IdleTimeout:       30 * time.Second, //nolint: gomnd // set timeout
 		ReadHeaderTimeout: 2 * time.Second,  //nolint: gomnd // set timeout
 	}
@@ -1,4 +1,5 @@
-package export
+// Package cliauth authorizes the commands that run in a terminal with Google, the token is stored in a file.
+package cliauth
 
 import (
 	"context"
@@ -11,11 +12,40 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
+	"github.com/urfave/cli"
 
 	"github.com/pkg/errors"
 	"golang.org/x/oauth2"
 )
 
+var FlagTokenFile = cli.StringFlag{
+	Name:  "tokenfile",
+	Usage: "the file where the token will be stored",
+	Value: "token.json",
+}
+
+var FlagAuthBindAddress = cli.StringFlag{
+	Name:  "auth-bind-address",
+	Usage: "bind to this address for the google authentication",
+	Value: "127.0.0.1:8000",
+}
+
+// Authenticate returns the client for the token file of FlagTokenFile, asking for authorization if there is no
+// token yet.
+func Authenticate(c *cli.Context, logger *zerolog.Logger) (*http.Client, error) {
+	client, err := getAuthenticatedClient(
+		logger,
+		c.String(FlagAuthBindAddress.Name),
+		c.String(FlagTokenFile.Name),
+		c.GlobalString("client_id"),
+		c.GlobalString("client_secret"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get authenticated client")
+	}
+	return client, nil
+}
+
 func getAuthenticatedClient(logger *zerolog.Logger, authAddress, tokenFile, clientID, clientSecret string) (*http.Client, error) {
 	var tokenBuf []byte
 	writeTokenFile := false
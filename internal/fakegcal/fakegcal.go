@@ -0,0 +1,506 @@
+// Package fakegcal implements a fake of the Google Calendar v3 API for tests.
+//
+// The fake serves calendarList.list, calendarList.get, calendars.get, events.list, including paging and sync tokens,
+// and freebusy.query from fixtures that are loaded from json files.
+// Recurring events are not expanded, with singleEvents the recurring events are omitted and only the
+// instances that are part of the fixture are returned.
+package fakegcal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/calendar/v3"
+)
+
+// Fixture holds the calendars the fake serves.
+type Fixture struct {
+	Calendars []*FixtureCalendar `json:"calendars"`
+}
+
+// FixtureCalendar is a single calendar with its events.
+type FixtureCalendar struct {
+	// Entry is returned by calendarList.list.
+	Entry *calendar.CalendarListEntry `json:"entry"`
+	// Calendar is returned by calendars.get, if nil it is derived from the Entry.
+	Calendar *calendar.Calendar `json:"calendar,omitempty"`
+	Events   []*calendar.Event  `json:"events"`
+}
+
+// LoadFixture reads a Fixture from a json file.
+func LoadFixture(path string) (*Fixture, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read `%s'", path)
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(buf, &fixture); err != nil {
+		return nil, errors.Wrapf(err, "unable to decode `%s'", path)
+	}
+	for i, cal := range fixture.Calendars {
+		if cal.Entry == nil || cal.Entry.Id == "" {
+			return nil, errors.Errorf("calendar %d in `%s' has no id", i, path)
+		}
+	}
+	return &fixture, nil
+}
+
+type change struct {
+	version int
+	eventID string
+}
+
+type calendarState struct {
+	fixture *FixtureCalendar
+	// changes holds the event ids that were changed in a version
+	changes []change
+}
+
+// Server is a fake Google Calendar API server.
+type Server struct {
+	*httptest.Server
+
+	// PageSize limits the number of items per page, in addition to the maxResults parameter.
+	// It can be used to test paging with small fixtures.
+	PageSize int
+
+	mu        sync.Mutex
+	calendars []*calendarState
+	version   int
+	// minSyncVersion is the oldest version a sync token is accepted for
+	minSyncVersion int
+	requests       []*http.Request
+}
+
+// NewServer starts a fake server that serves the fixture.
+// The server must be closed after use.
+func NewServer(fixture *Fixture) *Server {
+	s := &Server{}
+	for _, cal := range fixture.Calendars {
+		s.calendars = append(s.calendars, &calendarState{fixture: cal})
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns a client that sends all requests to the fake server, regardless of the host.
+// It can be passed to calendar.NewService with option.WithHTTPClient.
+func (s *Server) Client() *http.Client {
+	target, _ := url.Parse(s.URL)
+	return &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.URL.Scheme = target.Scheme
+			req.URL.Host = target.Host
+			req.Host = target.Host
+			return http.DefaultTransport.RoundTrip(req)
+		}),
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Requests returns all requests the server received so far.
+func (s *Server) Requests() []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*http.Request(nil), s.requests...)
+}
+
+// PutEvent adds or replaces an event of a calendar, the change is reported to sync requests.
+func (s *Server) PutEvent(calendarID string, ev *calendar.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cal := s.calendar(calendarID)
+	if cal == nil {
+		panic(fmt.Sprintf("no such calendar %s", calendarID))
+	}
+	s.version++
+	cal.changes = append(cal.changes, change{version: s.version, eventID: ev.Id})
+	for i := range cal.fixture.Events {
+		if cal.fixture.Events[i].Id == ev.Id {
+			cal.fixture.Events[i] = ev
+			return
+		}
+	}
+	cal.fixture.Events = append(cal.fixture.Events, ev)
+}
+
+// DeleteEvent marks an event of a calendar as cancelled, the change is reported to sync requests.
+func (s *Server) DeleteEvent(calendarID, eventID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cal := s.calendar(calendarID)
+	if cal == nil {
+		panic(fmt.Sprintf("no such calendar %s", calendarID))
+	}
+	s.version++
+	cal.changes = append(cal.changes, change{version: s.version, eventID: eventID})
+	for _, ev := range cal.fixture.Events {
+		if ev.Id == eventID {
+			ev.Status = "cancelled"
+		}
+	}
+}
+
+// ExpireSyncTokens invalidates all sync tokens that were issued so far,
+// requests with these tokens fail with 410 Gone.
+func (s *Server) ExpireSyncTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	s.minSyncVersion = s.version
+}
+
+// calendar returns the calendar with the id, primary is an alias for the primary calendar.
+func (s *Server) calendar(id string) *calendarState {
+	for _, cal := range s.calendars {
+		if cal.fixture.Entry.Id == id || (id == "primary" && cal.fixture.Entry.Primary) {
+			return cal
+		}
+	}
+	return nil
+}
+
+const basePath = "/calendar/v3/"
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+
+	path := strings.TrimPrefix(r.URL.EscapedPath(), basePath)
+	if path == r.URL.EscapedPath() {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if path == "freeBusy" {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		s.queryFreeBusy(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	parts := strings.Split(path, "/")
+	for i := range parts {
+		var err error
+		parts[i], err = url.PathUnescape(parts[i])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "malformed path")
+			return
+		}
+	}
+
+	switch {
+	case len(parts) == 3 && parts[0] == "users" && parts[1] == "me" && parts[2] == "calendarList":
+		s.listCalendars(w, r)
+	case len(parts) == 4 && parts[0] == "users" && parts[1] == "me" && parts[2] == "calendarList":
+		s.getCalendarListEntry(w, parts[3])
+	case len(parts) == 2 && parts[0] == "calendars":
+		s.getCalendar(w, parts[1])
+	case len(parts) == 3 && parts[0] == "calendars" && parts[2] == "events":
+		s.listEvents(w, r, parts[1])
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) listCalendars(w http.ResponseWriter, r *http.Request) {
+	items := make([]*calendar.CalendarListEntry, 0, len(s.calendars))
+	for _, cal := range s.calendars {
+		if cal.fixture.Entry.Hidden && r.URL.Query().Get("showHidden") != "true" {
+			continue
+		}
+		items = append(items, cal.fixture.Entry)
+	}
+	start, end, next, ok := s.page(w, r, len(items))
+	if !ok {
+		return
+	}
+	writeJSON(w, &calendar.CalendarList{
+		Kind:          "calendar#calendarList",
+		Items:         items[start:end],
+		NextPageToken: next,
+	})
+}
+
+func (s *Server) getCalendarListEntry(w http.ResponseWriter, id string) {
+	cal := s.calendar(id)
+	if cal == nil || cal.fixture.Entry.Deleted {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, cal.fixture.Entry)
+}
+
+func (s *Server) getCalendar(w http.ResponseWriter, id string) {
+	cal := s.calendar(id)
+	if cal == nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if cal.fixture.Calendar != nil {
+		writeJSON(w, cal.fixture.Calendar)
+		return
+	}
+	writeJSON(w, &calendar.Calendar{
+		Kind:        "calendar#calendar",
+		Id:          cal.fixture.Entry.Id,
+		Summary:     cal.fixture.Entry.Summary,
+		Description: cal.fixture.Entry.Description,
+		TimeZone:    cal.fixture.Entry.TimeZone,
+	})
+}
+
+func (s *Server) queryFreeBusy(w http.ResponseWriter, r *http.Request) {
+	var req calendar.FreeBusyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "malformed request")
+		return
+	}
+	timeMin, err := parseTime(req.TimeMin)
+	if err != nil || timeMin.IsZero() {
+		writeError(w, http.StatusBadRequest, "malformed timeMin")
+		return
+	}
+	timeMax, err := parseTime(req.TimeMax)
+	if err != nil || timeMax.IsZero() {
+		writeError(w, http.StatusBadRequest, "malformed timeMax")
+		return
+	}
+
+	result := &calendar.FreeBusyResponse{
+		Kind:      "calendar#freeBusy",
+		TimeMin:   req.TimeMin,
+		TimeMax:   req.TimeMax,
+		Calendars: make(map[string]calendar.FreeBusyCalendar),
+	}
+	for _, item := range req.Items {
+		cal := s.calendar(item.Id)
+		if cal == nil {
+			result.Calendars[item.Id] = calendar.FreeBusyCalendar{
+				Errors: []*calendar.Error{{Domain: "global", Reason: "notFound"}},
+			}
+			continue
+		}
+		result.Calendars[item.Id] = calendar.FreeBusyCalendar{Busy: busyPeriods(cal.fixture.Events, timeMin, timeMax)}
+	}
+	writeJSON(w, result)
+}
+
+// busyPeriods returns the time of the opaque single events between timeMin and timeMax that were not declined.
+// Overlapping events are not merged.
+func busyPeriods(events []*calendar.Event, timeMin, timeMax time.Time) []*calendar.TimePeriod {
+	var busy []*calendar.TimePeriod
+	for _, ev := range events {
+		if len(ev.Recurrence) > 0 || ev.Status == "cancelled" || ev.Transparency == "transparent" {
+			continue
+		}
+		if declined(ev) || !overlaps(ev, timeMin, timeMax) {
+			continue
+		}
+		start, end := eventTime(ev.Start), eventTime(ev.End)
+		if start.Before(timeMin) {
+			start = timeMin
+		}
+		if end.After(timeMax) {
+			end = timeMax
+		}
+		busy = append(busy, &calendar.TimePeriod{
+			Start: start.UTC().Format(time.RFC3339),
+			End:   end.UTC().Format(time.RFC3339),
+		})
+	}
+	return busy
+}
+
+func declined(ev *calendar.Event) bool {
+	for _, attendee := range ev.Attendees {
+		if attendee.Self && attendee.ResponseStatus == "declined" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) listEvents(w http.ResponseWriter, r *http.Request, id string) {
+	cal := s.calendar(id)
+	if cal == nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	query := r.URL.Query()
+	singleEvents := query.Get("singleEvents") == "true"
+	showDeleted := query.Get("showDeleted") == "true"
+
+	var items []*calendar.Event
+	if syncToken := query.Get("syncToken"); syncToken != "" {
+		if query.Get("timeMin") != "" || query.Get("timeMax") != "" {
+			writeError(w, http.StatusBadRequest, "syncToken cannot be combined with timeMin or timeMax")
+			return
+		}
+		version, err := strconv.Atoi(strings.TrimPrefix(syncToken, "sync-"))
+		if err != nil || version < s.minSyncVersion || version > s.version {
+			writeError(w, http.StatusGone, "sync token is no longer valid, a full sync is required")
+			return
+		}
+		changed := make(map[string]bool)
+		for _, c := range cal.changes {
+			if c.version > version {
+				changed[c.eventID] = true
+			}
+		}
+		for _, ev := range cal.fixture.Events {
+			// deleted events are always reported to sync requests
+			if changed[ev.Id] && (!singleEvents || len(ev.Recurrence) == 0) {
+				items = append(items, ev)
+			}
+		}
+	} else {
+		timeMin, err := parseTime(query.Get("timeMin"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "malformed timeMin")
+			return
+		}
+		timeMax, err := parseTime(query.Get("timeMax"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "malformed timeMax")
+			return
+		}
+		for _, ev := range cal.fixture.Events {
+			if singleEvents && len(ev.Recurrence) > 0 {
+				continue
+			}
+			if ev.Status == "cancelled" && !showDeleted && (singleEvents || ev.RecurringEventId == "") {
+				continue
+			}
+			if !overlaps(ev, timeMin, timeMax) {
+				continue
+			}
+			items = append(items, ev)
+		}
+	}
+
+	start, end, next, ok := s.page(w, r, len(items))
+	if !ok {
+		return
+	}
+	result := &calendar.Events{
+		Kind:             "calendar#events",
+		Summary:          cal.fixture.Entry.Summary,
+		TimeZone:         cal.fixture.Entry.TimeZone,
+		DefaultReminders: cal.fixture.Entry.DefaultReminders,
+		Items:            items[start:end],
+		NextPageToken:    next,
+	}
+	if next == "" {
+		result.NextSyncToken = "sync-" + strconv.Itoa(s.version)
+	}
+	writeJSON(w, result)
+}
+
+// page returns the range of items for the pageToken and maxResults of the request.
+func (s *Server) page(w http.ResponseWriter, r *http.Request, total int) (start, end int, next string, ok bool) {
+	query := r.URL.Query()
+	if token := query.Get("pageToken"); token != "" {
+		var err error
+		start, err = strconv.Atoi(strings.TrimPrefix(token, "page-"))
+		if err != nil || start < 0 || start > total {
+			writeError(w, http.StatusBadRequest, "malformed pageToken")
+			return 0, 0, "", false
+		}
+	}
+	size := total
+	if v := query.Get("maxResults"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "malformed maxResults")
+			return 0, 0, "", false
+		}
+		size = n
+	}
+	if s.PageSize > 0 && s.PageSize < size {
+		size = s.PageSize
+	}
+	end = start + size
+	if end >= total {
+		return start, total, "", true
+	}
+	return start, end, "page-" + strconv.Itoa(end), true
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func eventTime(dt *calendar.EventDateTime) time.Time {
+	if dt == nil {
+		return time.Time{}
+	}
+	if dt.Date != "" {
+		t, _ := time.Parse("2006-01-02", dt.Date)
+		return t
+	}
+	t, _ := time.Parse(time.RFC3339, dt.DateTime)
+	return t
+}
+
+// overlaps reports whether the event happens between timeMin and timeMax.
+// Recurring events and events without a time are always included.
+func overlaps(ev *calendar.Event, timeMin, timeMax time.Time) bool {
+	if len(ev.Recurrence) > 0 {
+		return true
+	}
+	start, end := eventTime(ev.Start), eventTime(ev.End)
+	if start.IsZero() || end.IsZero() {
+		start = eventTime(ev.OriginalStartTime)
+		end = start
+	}
+	if start.IsZero() {
+		return true
+	}
+	if !timeMax.IsZero() && !start.Before(timeMax) {
+		return false
+	}
+	if !timeMin.IsZero() && !end.After(timeMin) && !start.Equal(timeMin) {
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
@@ -0,0 +1,80 @@
+package fakegcal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+func TestSyncToken(t *testing.T) {
+	server := NewServer(&Fixture{
+		Calendars: []*FixtureCalendar{{
+			Entry: &calendar.CalendarListEntry{Id: "work@example.com", Summary: "Work"},
+			Events: []*calendar.Event{
+				{Id: "1", Summary: "One"},
+				{Id: "2", Summary: "Two"},
+				{Id: "3", Summary: "Three"},
+			},
+		}},
+	})
+	defer server.Close()
+	server.PageSize = 2
+
+	service, err := calendar.NewService(context.Background(), option.WithHTTPClient(server.Client()))
+	require.NoError(t, err)
+
+	list := func(syncToken string) ([]string, string, error) {
+		var ids []string
+		var pageToken string
+		for {
+			call := service.Events.List("work@example.com")
+			if syncToken != "" {
+				call.SyncToken(syncToken)
+			}
+			if pageToken != "" {
+				call.PageToken(pageToken)
+			}
+			events, err := call.Do()
+			if err != nil {
+				return nil, "", err
+			}
+			for _, ev := range events.Items {
+				ids = append(ids, ev.Id+":"+ev.Status)
+			}
+			if events.NextPageToken == "" {
+				return ids, events.NextSyncToken, nil
+			}
+			require.Empty(t, events.NextSyncToken)
+			pageToken = events.NextPageToken
+		}
+	}
+
+	ids, syncToken, err := list("")
+	require.NoError(t, err)
+	require.Equal(t, []string{"1:", "2:", "3:"}, ids)
+	require.NotEmpty(t, syncToken)
+
+	server.PutEvent("work@example.com", &calendar.Event{Id: "2", Summary: "Two (changed)"})
+	server.PutEvent("work@example.com", &calendar.Event{Id: "4", Summary: "Four"})
+	server.DeleteEvent("work@example.com", "1")
+
+	ids, syncToken, err = list(syncToken)
+	require.NoError(t, err)
+	require.Equal(t, []string{"1:cancelled", "2:", "4:"}, ids)
+
+	ids, _, err = list(syncToken)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+
+	server.ExpireSyncTokens()
+	_, _, err = list(syncToken)
+	var apiErr *googleapi.Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusGone, apiErr.Code)
+}
@@ -0,0 +1,34 @@
+package gti
+
+import (
+	"time"
+
+	"github.com/Eun/gcal-to-ics/pkg/ical"
+)
+
+func writeAlarms(enc ical.Writer, config *Config, ev *Event) {
+	for _, reminder := range ev.Reminders {
+		writeAlarm(enc, config, ev, reminder.Method, reminder.Before)
+	}
+}
+
+func writeAlarm(enc ical.Writer, config *Config, ev *Event, method string, before time.Duration) {
+	enc.Begin("VALARM")
+	// email alarms need a recipient, fall back to a display alarm if we don't have one
+	// or if the addresses of the participants must not be shared
+	if method == ReminderEmail && config.AccountEmail != "" && !config.HideFields.Attendees && !config.HideFields.Organizer {
+		description := ev.Summary
+		if ev.Description != "" {
+			description = ev.Description
+		}
+		enc.WriteProperty(&ical.Property{Name: "ACTION", Value: "EMAIL"})
+		enc.WriteProperty(&ical.Property{Name: "SUMMARY", Value: ev.Summary})
+		enc.WriteProperty(&ical.Property{Name: "DESCRIPTION", Value: description})
+		enc.WriteProperty(&ical.Property{Name: "ATTENDEE", Value: "mailto:" + config.AccountEmail})
+	} else {
+		enc.WriteProperty(&ical.Property{Name: "ACTION", Value: "DISPLAY"})
+		enc.WriteProperty(&ical.Property{Name: "DESCRIPTION", Value: ev.Summary})
+	}
+	enc.WriteProperty(&ical.Property{Name: "TRIGGER", Value: ical.FormatDuration(-before)})
+	enc.End("VALARM")
+}
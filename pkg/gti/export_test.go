@@ -0,0 +1,249 @@
+package gti
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Eun/gcal-to-ics/internal/fakegcal"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func newFakeServer(t *testing.T, fixture string) *fakegcal.Server {
+	f, err := fakegcal.LoadFixture(filepath.Join("testdata", fixture))
+	require.NoError(t, err)
+	server := fakegcal.NewServer(f)
+	// force paging
+	server.PageSize = 2
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		config  Config
+	}{
+		{
+			name:    "ics",
+			fixture: "calendar.json",
+			config:  Config{Format: "ics"},
+		},
+		{
+			name:    "jcal",
+			fixture: "calendar.json",
+			config:  Config{Format: "jcal"},
+		},
+		{
+			name:    "xcal",
+			fixture: "calendar.json",
+			config:  Config{Format: "xcal"},
+		},
+		{
+			name:    "email-alarms",
+			fixture: "calendar.json",
+			config:  Config{Format: "ics", AccountEmail: "me@example.com"},
+		},
+		{
+			// the address of the account is not shared if the attendees are hidden
+			name:    "email-alarms-hidden",
+			fixture: "calendar.json",
+			config:  Config{Format: "ics", AccountEmail: "me@example.com", HideFields: HideFields{Attendees: true}},
+		},
+		{
+			name:    "hidden",
+			fixture: "calendar.json",
+			config: Config{
+				Format: "ics",
+				HideFields: HideFields{
+					UID:          true,
+					Organizer:    true,
+					Attendees:    true,
+					Visibility:   true,
+					Description:  true,
+					Location:     true,
+					Conference:   true,
+					Transparency: true,
+					Status:       true,
+					Reminders:    true,
+				},
+			},
+		},
+		{
+			name:    "overwritten",
+			fixture: "calendar.json",
+			config: Config{
+				Format: "ics",
+				OverwriteFields: OverwriteFields{
+					CalendarName: "Busy",
+					Summary:      "Blocked",
+					Organizer:    "mailto:someone@example.com",
+					Visibility:   "public",
+					Description:  "See the work calendar",
+					Location:     "Office",
+					Conference:   "https://example.com/meeting",
+					Transparency: "transparent",
+					Status:       "confirmed",
+					Alarm:        5 * time.Minute,
+				},
+			},
+		},
+		{
+			name:    "templated",
+			fixture: "calendar.json",
+			config: Config{
+				Format: "ics",
+				TemplateFields: TemplateFields{
+					Summary:     "[{{ .Calendar.Summary }}] {{ .Summary | truncate 8 }}",
+					Description: `{{ formatTime "Mon 15:04" .Start }} {{ .Location | lower }}`,
+					Location:    `{{ regexReplace "^Room (\\d+)$" "R$1" .Location }}`,
+				},
+			},
+		},
+		{
+			name:    "busy",
+			fixture: "calendar.json",
+			config:  Config{Format: "ics", AccountEmail: "me@example.com", Privacy: PrivacyBusy, BusySlot: 30 * time.Minute},
+		},
+		{
+			name:    "freebusy",
+			fixture: "calendar.json",
+			config:  Config{Format: "freebusy", AccountEmail: "me@example.com"},
+		},
+		{
+			name:    "recurrence",
+			fixture: "recurrence.json",
+			config:  Config{Format: "ics", KeepRecurrence: true},
+		},
+		{
+			name:    "recurrence-busy",
+			fixture: "recurrence.json",
+			config:  Config{Format: "ics", KeepRecurrence: true, Privacy: PrivacyBusy, BusyLabel: "Occupied", BusySlot: time.Hour},
+		},
+		{
+			name:    "recurrence-skip-declined",
+			fixture: "recurrence.json",
+			config:  Config{Format: "ics", KeepRecurrence: true, Filter: Filter{SkipDeclined: true}},
+		},
+		{
+			name:    "recurrence-expanded",
+			fixture: "recurrence.json",
+			config:  Config{Format: "ics"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t, tt.fixture)
+
+			var buf bytes.Buffer
+			config := tt.config
+			config.Logger = nopLogger()
+			config.StartFrom = time.Date(2024, time.May, 6, 0, 0, 0, 0, time.UTC)
+			config.EndOn = time.Date(2024, time.May, 13, 0, 0, 0, 0, time.UTC)
+			config.CalendarName = "Work"
+			config.Writer = &buf
+			config.Client = server.Client()
+			config.Version = "test"
+			require.NoError(t, Export(&config))
+
+			golden := filepath.Join("testdata", "export-"+tt.name+".golden")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, buf.Bytes(), 0o600))
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			require.Equal(t, string(want), buf.String())
+		})
+	}
+}
+
+func TestExportNoSuchCalendar(t *testing.T) {
+	server := newFakeServer(t, "calendar.json")
+
+	var buf bytes.Buffer
+	err := Export(&Config{
+		Format:       "ics",
+		Logger:       nopLogger(),
+		CalendarName: "Missing",
+		Writer:       &buf,
+		Client:       server.Client(),
+	})
+	require.EqualError(t, err, "no such calendar `Missing', candidates: \"Private\" (private@example.com), \"Work\" (work@example.com)")
+	require.Empty(t, buf.String())
+}
+
+func TestFindCalendar(t *testing.T) {
+	server := newFakeServer(t, "calendar.json")
+	service, err := calendar.NewService(context.Background(), option.WithHTTPClient(server.Client()))
+	require.NoError(t, err)
+
+	// the deleted calendar with the same name is skipped, the hidden one on the next page is found
+	entry, err := findCalendar(context.Background(), service, "Work")
+	require.NoError(t, err)
+	require.Equal(t, "work@example.com", entry.Id)
+
+	var pages int
+	for _, r := range server.Requests() {
+		if r.URL.Path == "/calendar/v3/users/me/calendarList" {
+			pages++
+		}
+	}
+	require.Equal(t, 2, pages)
+
+	for name, id := range map[string]string{
+		"primary":     "private@example.com",
+		"W*":          "work@example.com",
+		"/^priv/i":    "",
+		"/(?i)^priv/": "private@example.com",
+	} {
+		entry, err := findCalendar(context.Background(), service, name)
+		if id == "" {
+			require.Error(t, err, name)
+			continue
+		}
+		require.NoError(t, err, name)
+		require.Equal(t, id, entry.Id, name)
+	}
+
+	_, err = findCalendar(context.Background(), service, "/^(Private|Work)$/")
+	require.EqualError(t, err, "calendar `/^(Private|Work)$/' is ambiguous, candidates: \"Private\" (private@example.com), \"Work\" (work@example.com)")
+	_, err = findCalendar(context.Background(), service, "/(/")
+	require.Error(t, err)
+}
+
+func TestFetchCalendarID(t *testing.T) {
+	server := newFakeServer(t, "calendar.json")
+	config := &Config{
+		Logger:     nopLogger(),
+		StartFrom:  time.Date(2024, time.May, 6, 0, 0, 0, 0, time.UTC),
+		EndOn:      time.Date(2024, time.May, 13, 0, 0, 0, 0, time.UTC),
+		CalendarID: "work@example.com",
+		Client:     server.Client(),
+	}
+	cal, events, err := Fetch(context.Background(), config)
+	require.NoError(t, err)
+	require.Equal(t, "work@example.com", cal.ID)
+	require.Len(t, events, 4)
+	// the default reminders of the calendar list entry are used
+	require.Equal(t, []Reminder{{Method: ReminderDisplay, Before: 10 * time.Minute}}, events[0].Reminders)
+
+	config.CalendarID = "primary"
+	cal, _, err = Fetch(context.Background(), config)
+	require.NoError(t, err)
+	require.Equal(t, "private@example.com", cal.ID)
+
+	config.CalendarID = "missing@example.com"
+	_, _, err = Fetch(context.Background(), config)
+	require.Error(t, err)
+}
@@ -0,0 +1,134 @@
+package gti
+
+import (
+	"strings"
+)
+
+// applyCalendarFields applies the overwritten fields to the calendar.
+func applyCalendarFields(config *Config, cal *Calendar) {
+	if config.OverwriteFields.CalendarName != "" {
+		cal.Summary = config.OverwriteFields.CalendarName
+	}
+}
+
+// applyEventFields removes the hidden fields from the event and applies the overwritten fields.
+func applyEventFields(config *Config, ev *Event) {
+	if config.HideFields.UID {
+		ev.UID = ""
+	}
+
+	if config.OverwriteFields.Summary != "" {
+		ev.Summary = config.OverwriteFields.Summary
+	}
+
+	if config.HideFields.Description {
+		ev.Description = ""
+	} else if config.OverwriteFields.Description != "" {
+		ev.Description = config.OverwriteFields.Description
+	}
+
+	if config.HideFields.Transparency {
+		ev.Transparency = ""
+	} else {
+		if config.OverwriteFields.Transparency != "" {
+			ev.Transparency = strings.ToUpper(config.OverwriteFields.Transparency)
+		}
+		if ev.Transparency != TransparencyTransparent {
+			ev.Transparency = TransparencyOpaque
+		}
+	}
+
+	if config.HideFields.Location {
+		ev.Location = ""
+	} else if config.OverwriteFields.Location != "" {
+		ev.Location = config.OverwriteFields.Location
+	}
+
+	if config.HideFields.Visibility {
+		ev.Visibility = ""
+	} else if config.OverwriteFields.Visibility != "" {
+		ev.Visibility = strings.ToUpper(config.OverwriteFields.Visibility)
+	}
+
+	if config.HideFields.Conference {
+		ev.ConferenceURI = ""
+	} else if config.OverwriteFields.Conference != "" {
+		ev.ConferenceURI = config.OverwriteFields.Conference
+	}
+
+	if config.HideFields.Organizer {
+		ev.Organizer = nil
+	} else if config.OverwriteFields.Organizer != "" {
+		if strings.Contains(config.OverwriteFields.Organizer, "@") {
+			ev.Organizer = &Person{Email: strings.TrimPrefix(config.OverwriteFields.Organizer, "mailto:")}
+		} else {
+			ev.Organizer = &Person{DisplayName: config.OverwriteFields.Organizer}
+		}
+	}
+
+	if config.HideFields.Attendees {
+		ev.Attendees = nil
+	} else {
+		// overwrite status if we are an attendee
+		for _, attendee := range ev.Attendees {
+			if strings.EqualFold(attendee.Email, config.AccountEmail) {
+				ev.Status = attendeeStatus(attendee.ResponseStatus)
+			}
+		}
+	}
+
+	if config.HideFields.Status {
+		ev.Status = ""
+	} else if config.OverwriteFields.Status != "" {
+		ev.Status = strings.ToUpper(config.OverwriteFields.Status)
+	}
+
+	if config.HideFields.Reminders {
+		ev.Reminders = nil
+	} else if config.OverwriteFields.Alarm > 0 {
+		ev.Reminders = []Reminder{{Method: ReminderDisplay, Before: config.OverwriteFields.Alarm}}
+	}
+}
+
+// hideEventFields removes the hidden fields of the event, without overwriting any field.
+func hideEventFields(hide *HideFields, ev *Event) {
+	if hide.UID {
+		ev.UID = ""
+	}
+	if hide.Description {
+		ev.Description = ""
+	}
+	if hide.Transparency {
+		ev.Transparency = ""
+	}
+	if hide.Location {
+		ev.Location = ""
+	}
+	if hide.Visibility {
+		ev.Visibility = ""
+	}
+	if hide.Conference {
+		ev.ConferenceURI = ""
+	}
+	if hide.Organizer {
+		ev.Organizer = nil
+	}
+	if hide.Attendees {
+		ev.Attendees = nil
+	}
+	if hide.Status {
+		ev.Status = ""
+	}
+	if hide.Reminders {
+		ev.Reminders = nil
+	}
+}
+
+// attendeeStatus returns the event status for the response of an attendee.
+// Only a tentative response has a matching status, for the other responses the status is unknown.
+func attendeeStatus(responseStatus string) string {
+	if responseStatus == ResponseTentative {
+		return StatusTentative
+	}
+	return ""
+}
@@ -0,0 +1,213 @@
+package gti
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Filter selects the events that are exported, an event is exported if it passes every rule that is set.
+// The rules are evaluated against the events as they were fetched, before any field is hidden or overwritten.
+type Filter struct {
+	// IncludeSummary and ExcludeSummary are regular expressions the summary has to match, or must not match.
+	IncludeSummary string `yaml:"include_summary" json:"include_summary,omitempty"`
+	ExcludeSummary string `yaml:"exclude_summary" json:"exclude_summary,omitempty"`
+	// IncludeColors and ExcludeColors are Google color ids, e.g. 11.
+	IncludeColors []string `yaml:"include_colors" json:"include_colors,omitempty"`
+	ExcludeColors []string `yaml:"exclude_colors" json:"exclude_colors,omitempty"`
+	// IncludeEventTypes and ExcludeEventTypes are Google event types, e.g. default, focusTime or outOfOffice.
+	IncludeEventTypes []string `yaml:"include_event_types" json:"include_event_types,omitempty"`
+	ExcludeEventTypes []string `yaml:"exclude_event_types" json:"exclude_event_types,omitempty"`
+	// SkipDeclined drops the events the owner of the calendar declined.
+	SkipDeclined bool `yaml:"skip_declined" json:"skip_declined,omitempty"`
+	// Transparency keeps only the events with this transparency, opaque or transparent.
+	Transparency string `yaml:"transparency" json:"transparency,omitempty"`
+	// Visibility keeps only the events with this visibility, default, public or private.
+	Visibility string `yaml:"visibility" json:"visibility,omitempty"`
+	// MinDuration and MaxDuration limit the length of the events.
+	MinDuration time.Duration `yaml:"min_duration" json:"min_duration,omitempty"`
+	MaxDuration time.Duration `yaml:"max_duration" json:"max_duration,omitempty"`
+	// Weekdays keeps only the events that start on these days, e.g. mon or monday.
+	Weekdays []string `yaml:"weekdays" json:"weekdays,omitempty"`
+	// Hours keeps only the events that start in this range of hours, e.g. 9-17 for events starting
+	// between 09:00 and 16:59. All day events are not affected.
+	Hours string `yaml:"hours" json:"hours,omitempty"`
+}
+
+// Validate reports whether the rules of the filter are valid.
+func (f *Filter) Validate() error {
+	_, err := newEventFilter(f)
+	return err
+}
+
+// eventFilter is a Filter with its rules parsed.
+type eventFilter struct {
+	*Filter
+	includeSummary *regexp.Regexp
+	excludeSummary *regexp.Regexp
+	weekdays       map[time.Weekday]bool
+	fromHour       int
+	toHour         int
+}
+
+func newEventFilter(f *Filter) (*eventFilter, error) {
+	ef := &eventFilter{Filter: f}
+	var err error
+	if f.IncludeSummary != "" {
+		if ef.includeSummary, err = regexp.Compile(f.IncludeSummary); err != nil {
+			return nil, errors.Wrapf(err, "invalid include_summary `%s'", f.IncludeSummary)
+		}
+	}
+	if f.ExcludeSummary != "" {
+		if ef.excludeSummary, err = regexp.Compile(f.ExcludeSummary); err != nil {
+			return nil, errors.Wrapf(err, "invalid exclude_summary `%s'", f.ExcludeSummary)
+		}
+	}
+
+	switch strings.ToUpper(f.Transparency) {
+	case "", TransparencyOpaque, TransparencyTransparent:
+	default:
+		return nil, errors.Errorf("invalid transparency `%s'", f.Transparency)
+	}
+	switch strings.ToUpper(f.Visibility) {
+	case "", "DEFAULT", VisibilityPublic, VisibilityPrivate:
+	default:
+		return nil, errors.Errorf("invalid visibility `%s'", f.Visibility)
+	}
+	if f.MaxDuration != 0 && f.MaxDuration < f.MinDuration {
+		return nil, errors.New("max_duration must not be shorter than min_duration")
+	}
+
+	if len(f.Weekdays) > 0 {
+		ef.weekdays = make(map[time.Weekday]bool)
+		for _, name := range f.Weekdays {
+			day, ok := parseWeekday(name)
+			if !ok {
+				return nil, errors.Errorf("invalid weekday `%s'", name)
+			}
+			ef.weekdays[day] = true
+		}
+	}
+
+	if f.Hours != "" {
+		from, to, ok := strings.Cut(f.Hours, "-")
+		ef.fromHour, err = strconv.Atoi(strings.TrimSpace(from))
+		if err == nil {
+			ef.toHour, err = strconv.Atoi(strings.TrimSpace(to))
+		}
+		//nolint: gomnd // hours of a day
+		if !ok || err != nil || ef.fromHour < 0 || ef.toHour > 24 || ef.fromHour >= ef.toHour {
+			return nil, errors.Errorf("invalid hours `%s'", f.Hours)
+		}
+	}
+	return ef, nil
+}
+
+// parseWeekday parses the name of a weekday, it can be abbreviated to at least three letters.
+func parseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	//nolint: gomnd // the shortest abbreviation
+	if len(name) < 3 {
+		return 0, false
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.HasPrefix(strings.ToLower(day.String()), name) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// match reports whether the event passes every rule of the filter.
+func (f *eventFilter) match(ev *Event) bool {
+	if f.includeSummary != nil && !f.includeSummary.MatchString(ev.Summary) {
+		return false
+	}
+	if f.excludeSummary != nil && f.excludeSummary.MatchString(ev.Summary) {
+		return false
+	}
+	if len(f.IncludeColors) > 0 && !containsFold(f.IncludeColors, ev.ColorID) {
+		return false
+	}
+	if containsFold(f.ExcludeColors, ev.ColorID) {
+		return false
+	}
+	if len(f.IncludeEventTypes) > 0 && !containsFold(f.IncludeEventTypes, eventType(ev)) {
+		return false
+	}
+	if containsFold(f.ExcludeEventTypes, eventType(ev)) {
+		return false
+	}
+	if f.SkipDeclined && declined(ev) {
+		return false
+	}
+	if f.Transparency != "" && !strings.EqualFold(f.Transparency, transparency(ev)) {
+		return false
+	}
+	if f.Visibility != "" && !strings.EqualFold(f.Visibility, visibility(ev)) {
+		return false
+	}
+
+	duration := freeBusyTime(ev.End).Sub(freeBusyTime(ev.Start))
+	if f.MinDuration != 0 && duration < f.MinDuration {
+		return false
+	}
+	if f.MaxDuration != 0 && duration > f.MaxDuration {
+		return false
+	}
+
+	start := localTime(ev.Start)
+	if f.weekdays != nil && !f.weekdays[start.Weekday()] {
+		return false
+	}
+	if f.Hours != "" && !ev.Start.AllDay && (start.Hour() < f.fromHour || start.Hour() >= f.toHour) {
+		return false
+	}
+	return true
+}
+
+// declined reports whether the owner of the calendar declined the event.
+func declined(ev *Event) bool {
+	for _, attendee := range ev.Attendees {
+		if attendee.Self && attendee.ResponseStatus == ResponseDeclined {
+			return true
+		}
+	}
+	return false
+}
+
+// eventType returns the Google event type of the event, events without a type are default events.
+func eventType(ev *Event) string {
+	if ev.EventType == "" {
+		return "default"
+	}
+	return ev.EventType
+}
+
+// transparency returns the transparency of the event, events are opaque unless they are transparent.
+func transparency(ev *Event) string {
+	if ev.Transparency == TransparencyTransparent {
+		return TransparencyTransparent
+	}
+	return TransparencyOpaque
+}
+
+// visibility returns the visibility of the event, or DEFAULT if it uses the visibility of the calendar.
+func visibility(ev *Event) string {
+	if ev.Visibility == "" {
+		return "DEFAULT"
+	}
+	return ev.Visibility
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package gti
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	berlin := time.FixedZone("CEST", 2*60*60)
+	// Wednesday
+	at := func(hour int, d time.Duration) (EventTime, EventTime) {
+		start := time.Date(2024, time.May, 8, hour, 0, 0, 0, berlin)
+		return EventTime{Time: start, TimeZone: "Europe/Berlin"}, EventTime{Time: start.Add(d), TimeZone: "Europe/Berlin"}
+	}
+	ev := func(summary string, hour int, d time.Duration, f func(ev *Event)) Event {
+		ev := Event{Summary: summary}
+		ev.Start, ev.End = at(hour, d)
+		if f != nil {
+			f(&ev)
+		}
+		return ev
+	}
+	events := []Event{
+		ev("Standup", 9, 15*time.Minute, nil),
+		ev("Focus", 10, 2*time.Hour, func(ev *Event) { ev.EventType = "focusTime" }),
+		ev("Lunch", 12, time.Hour, func(ev *Event) { ev.Transparency = TransparencyTransparent }),
+		ev("1:1 Alice", 14, 30*time.Minute, func(ev *Event) { ev.Visibility = VisibilityPrivate; ev.ColorID = "11" }),
+		ev("Party", 18, 3*time.Hour, func(ev *Event) {
+			ev.Attendees = []Attendee{{Person: Person{Email: "me@example.com"}, Self: true, ResponseStatus: ResponseDeclined}}
+		}),
+		{
+			Summary: "Vacation",
+			Start:   EventTime{Time: time.Date(2024, time.May, 11, 0, 0, 0, 0, time.UTC), AllDay: true},
+			End:     EventTime{Time: time.Date(2024, time.May, 13, 0, 0, 0, 0, time.UTC), AllDay: true},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "none", want: []string{"Standup", "Focus", "Lunch", "1:1 Alice", "Party", "Vacation"}},
+		{name: "include summary", filter: Filter{IncludeSummary: "(?i)^(standup|lunch)$"}, want: []string{"Standup", "Lunch"}},
+		{name: "exclude summary", filter: Filter{ExcludeSummary: "^1:1"}, want: []string{"Standup", "Focus", "Lunch", "Party", "Vacation"}},
+		{name: "include colors", filter: Filter{IncludeColors: []string{"11"}}, want: []string{"1:1 Alice"}},
+		{name: "exclude event types", filter: Filter{ExcludeEventTypes: []string{"focustime"}}, want: []string{"Standup", "Lunch", "1:1 Alice", "Party", "Vacation"}},
+		{name: "include event types", filter: Filter{IncludeEventTypes: []string{"default"}}, want: []string{"Standup", "Lunch", "1:1 Alice", "Party", "Vacation"}},
+		{name: "skip declined", filter: Filter{SkipDeclined: true}, want: []string{"Standup", "Focus", "Lunch", "1:1 Alice", "Vacation"}},
+		{name: "transparency", filter: Filter{Transparency: "transparent"}, want: []string{"Lunch"}},
+		{name: "visibility", filter: Filter{Visibility: "default"}, want: []string{"Standup", "Focus", "Lunch", "Party", "Vacation"}},
+		{name: "duration", filter: Filter{MinDuration: 30 * time.Minute, MaxDuration: 2 * time.Hour}, want: []string{"Focus", "Lunch", "1:1 Alice"}},
+		{name: "weekdays", filter: Filter{Weekdays: []string{"Sat", "sunday"}}, want: []string{"Vacation"}},
+		{name: "hours", filter: Filter{Hours: "9-12"}, want: []string{"Standup", "Focus", "Vacation"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newEventFilter(&tt.filter)
+			require.NoError(t, err)
+			var got []string
+			for i := range events {
+				if filter.match(&events[i]) {
+					got = append(got, events[i].Summary)
+				}
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, filter := range []Filter{
+		{IncludeSummary: "("},
+		{Transparency: "maybe"},
+		{Visibility: "secret"},
+		{MinDuration: time.Hour, MaxDuration: time.Minute},
+		{Weekdays: []string{"mo"}},
+		{Hours: "17-9"},
+		{Hours: "9"},
+		{Hours: "0-25"},
+	} {
+		filter := filter
+		require.Error(t, filter.Validate(), "%+v", filter)
+	}
+}
+
+func TestWriteFilter(t *testing.T) {
+	var buf bytes.Buffer
+	config := &Config{
+		Format:  "ics",
+		Logger:  nopLogger(),
+		Writer:  &buf,
+		Version: "test",
+		Filter:  Filter{ExcludeSummary: "(?i)private"},
+	}
+	start := time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC)
+	events := []Event{
+		{Summary: "Meeting", Start: EventTime{Time: start}, End: EventTime{Time: start.Add(time.Hour)}},
+		{Summary: "Private appointment", Start: EventTime{Time: start}, End: EventTime{Time: start.Add(time.Hour)}},
+	}
+	require.NoError(t, Write(config, &Calendar{ID: "work"}, events))
+	require.Contains(t, buf.String(), "SUMMARY:Meeting")
+	require.NotContains(t, buf.String(), "Private")
+
+	// an excluded instance is not created again by its series
+	buf.Reset()
+	config.KeepRecurrence = true
+	events = []Event{
+		{
+			UID:        "sync@example.com",
+			Summary:    "Sync",
+			Start:      EventTime{Time: start},
+			End:        EventTime{Time: start.Add(time.Hour)},
+			Recurrence: []string{"RRULE:FREQ=DAILY;COUNT=3"},
+		},
+		{
+			UID:          "sync@example.com",
+			Summary:      "Private sync",
+			Start:        EventTime{Time: start.AddDate(0, 0, 1)},
+			End:          EventTime{Time: start.AddDate(0, 0, 1).Add(time.Hour)},
+			RecurrenceID: &EventTime{Time: start.AddDate(0, 0, 1)},
+		},
+	}
+	require.NoError(t, Write(config, &Calendar{ID: "work"}, events))
+	require.Contains(t, buf.String(), "RRULE:FREQ=DAILY;COUNT=3\r\nEXDATE:20240502T100000Z\r\n")
+	require.NotContains(t, buf.String(), "Private")
+	require.Equal(t, []string{"RRULE:FREQ=DAILY;COUNT=3"}, events[0].Recurrence)
+
+	config.Filter.ExcludeSummary = "("
+	require.Error(t, Write(config, &Calendar{ID: "work"}, events))
+}
@@ -0,0 +1,114 @@
+package gti
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Formatter writes a calendar in a specific format to the Writer of the Config.
+// The hidden and overwritten fields are already applied to the calendar and events that are passed to the Formatter.
+type Formatter interface {
+	// BeginCalendar is called once before any event is written.
+	BeginCalendar(cal *Calendar) error
+	// WriteEvent is called for every event that should be exported.
+	WriteEvent(ev *Event) error
+	// EndCalendar is called once after all events have been written.
+	EndCalendar() error
+}
+
+// Format describes an output format.
+type Format struct {
+	// Name is the name that is used to select the format, e.g. ics.
+	Name string
+	// ContentType is the mime type of the output.
+	ContentType string
+	// New creates a new Formatter for a single export.
+	New func(config *Config) Formatter
+	// BusyOnly formats are only passed the events that block time, transparent and declined events are dropped.
+	BusyOnly bool
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   = map[string]Format{
+		"ics": {
+			Name:        "ics",
+			ContentType: "text/calendar; charset=utf-8",
+			New:         newICSFormatter,
+		},
+		"json": {
+			Name:        "json",
+			ContentType: "application/calendar+json; charset=utf-8",
+			New:         newJCalFormatter,
+		},
+		"jcal": {
+			Name:        "jcal",
+			ContentType: "application/calendar+json; charset=utf-8",
+			New:         newJCalFormatter,
+		},
+		"xcal": {
+			Name:        "xcal",
+			ContentType: "application/calendar+xml; charset=utf-8",
+			New:         newXCalFormatter,
+		},
+		"freebusy": {
+			Name:        "freebusy",
+			ContentType: "text/calendar; charset=utf-8",
+			New:         newFreeBusyFormatter,
+			BusyOnly:    true,
+		},
+		"ifb": {
+			Name:        "ifb",
+			ContentType: "text/calendar; charset=utf-8",
+			New:         newFreeBusyFormatter,
+			BusyOnly:    true,
+		},
+	}
+)
+
+// RegisterFormat makes a format available for exports.
+// It returns an error if a format with the same name is already registered.
+func RegisterFormat(format Format) error {
+	if format.Name == "" {
+		return errors.New("format name cannot be empty")
+	}
+	if format.New == nil {
+		return errors.Errorf("format `%s' has no New function", format.Name)
+	}
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	if _, ok := formats[format.Name]; ok {
+		return errors.Errorf("format `%s' is already registered", format.Name)
+	}
+	formats[format.Name] = format
+	return nil
+}
+
+// LookupFormat returns the registered format with the specified name.
+func LookupFormat(name string) (Format, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	format, ok := formats[name]
+	return format, ok
+}
+
+// Formats returns the names of all registered formats, sorted by name.
+func Formats() []string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ContentType returns the mime type of the specified format.
+// It returns an empty string if the format is not supported.
+func ContentType(name string) string {
+	format, _ := LookupFormat(name)
+	return format.ContentType
+}
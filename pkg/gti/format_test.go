@@ -0,0 +1,35 @@
+package gti
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type nopFormatter struct{}
+
+func (nopFormatter) BeginCalendar(*Calendar) error { return nil }
+func (nopFormatter) WriteEvent(*Event) error       { return nil }
+func (nopFormatter) EndCalendar() error            { return nil }
+
+func TestRegisterFormat(t *testing.T) {
+	require.Error(t, RegisterFormat(Format{Name: "ics", New: func(*Config) Formatter { return nopFormatter{} }}))
+	require.Error(t, RegisterFormat(Format{Name: "nop"}))
+
+	require.NoError(t, RegisterFormat(Format{
+		Name:        "nop",
+		ContentType: "text/plain",
+		New:         func(*Config) Formatter { return nopFormatter{} },
+	}))
+	defer func() {
+		formatsMu.Lock()
+		delete(formats, "nop")
+		formatsMu.Unlock()
+	}()
+
+	format, ok := LookupFormat("nop")
+	require.True(t, ok)
+	require.Equal(t, "text/plain", format.ContentType)
+	require.Equal(t, "text/plain", ContentType("nop"))
+	require.Contains(t, Formats(), "nop")
+}
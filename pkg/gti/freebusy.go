@@ -0,0 +1,237 @@
+package gti
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/Eun/gcal-to-ics/pkg/ical"
+	"github.com/pkg/errors"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// Free/busy types of the FREEBUSY periods.
+const (
+	FreeBusyBusy          = "BUSY"
+	FreeBusyBusyTentative = "BUSY-TENTATIVE"
+)
+
+// freeBusyPeriod is a time range the calendar is busy.
+type freeBusyPeriod struct {
+	start, end time.Time
+}
+
+// freeBusyFormatter writes a VFREEBUSY component with the busy periods of the events in the time range of the config.
+// Only events that block time are passed to it, see Format.BusyOnly.
+type freeBusyFormatter struct {
+	config  *Config
+	enc     ical.Writer
+	cal     *Calendar
+	periods map[string][]freeBusyPeriod
+	stamp   time.Time
+}
+
+func newFreeBusyFormatter(config *Config) Formatter {
+	return &freeBusyFormatter{
+		config:  config,
+		enc:     ical.NewEncoder(config.Writer),
+		periods: make(map[string][]freeBusyPeriod),
+	}
+}
+
+func (f *freeBusyFormatter) BeginCalendar(cal *Calendar) error {
+	f.cal = cal
+	return nil
+}
+
+func (f *freeBusyFormatter) WriteEvent(ev *Event) error {
+	if len(ev.Recurrence) > 0 {
+		return errors.Errorf("recurring event `%s' can not be written as free/busy time, recurrences must be expanded", ev.ID)
+	}
+	start, end := freeBusyTime(ev.Start), freeBusyTime(ev.End)
+	if !f.config.StartFrom.IsZero() && start.Before(f.config.StartFrom) {
+		start = f.config.StartFrom
+	}
+	if !f.config.EndOn.IsZero() && end.After(f.config.EndOn) {
+		end = f.config.EndOn
+	}
+	if !end.After(start) {
+		return nil
+	}
+
+	fbType := FreeBusyBusy
+	if tentative(ev) {
+		fbType = FreeBusyBusyTentative
+	}
+	f.periods[fbType] = append(f.periods[fbType], freeBusyPeriod{start: start, end: end})
+	if ev.Updated.After(f.stamp) {
+		f.stamp = ev.Updated
+	}
+	return nil
+}
+
+func (f *freeBusyFormatter) EndCalendar() error {
+	if f.cal == nil {
+		return errors.New("calendar was not started")
+	}
+	if err := writeHeader(f.enc, f.config, f.cal); err != nil {
+		return errors.Wrapf(err, "unable to write header")
+	}
+
+	// the last change of the events is used, so the output only changes if the busy time changes
+	stamp := f.stamp
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+
+	f.enc.Begin("VFREEBUSY")
+	f.enc.WriteProperty(&ical.Property{Name: "UID", Value: "freebusy-" + f.cal.ID})
+	f.enc.WriteProperty(&ical.Property{Name: "DTSTAMP", Value: ical.FormatDateTimeUTC(stamp)})
+	if !f.config.StartFrom.IsZero() {
+		f.enc.WriteProperty(&ical.Property{Name: "DTSTART", Value: ical.FormatDateTimeUTC(f.config.StartFrom)})
+	}
+	if !f.config.EndOn.IsZero() {
+		f.enc.WriteProperty(&ical.Property{Name: "DTEND", Value: ical.FormatDateTimeUTC(f.config.EndOn)})
+	}
+	// like in the email alarms, the address of the account is not shared if the people of the events are hidden
+	hidden := f.config.HideFields.Organizer || f.config.HideFields.Attendees || f.config.Privacy == PrivacyBusy
+	if p := calAddressProperty("ORGANIZER", f.config.AccountEmail, ""); p != nil && !hidden {
+		f.enc.WriteProperty(p)
+	}
+	for _, fbType := range []string{FreeBusyBusy, FreeBusyBusyTentative} {
+		for _, period := range mergeFreeBusyPeriods(f.periods[fbType]) {
+			f.enc.WriteProperty(&ical.Property{
+				Name:   "FREEBUSY",
+				Params: []ical.Param{{Name: "FBTYPE", Values: []string{fbType}}},
+				Value:  ical.FormatDateTimeUTC(period.start) + "/" + ical.FormatDateTimeUTC(period.end),
+			})
+		}
+	}
+	f.enc.End("VFREEBUSY")
+	if err := f.enc.Err(); err != nil {
+		return errors.Wrap(err, "unable to write free/busy time")
+	}
+
+	if err := writeTrailer(f.enc); err != nil {
+		return errors.Wrapf(err, "unable to write trailer")
+	}
+	return nil
+}
+
+// freeBusyTime returns the point in time of t, all day events start at midnight in their timezone.
+func freeBusyTime(t EventTime) time.Time {
+	if !t.AllDay {
+		return t.Time
+	}
+	loc := loadLocation(t.TimeZone)
+	if loc == nil {
+		loc = time.UTC
+	}
+	y, m, d := t.Time.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// tentative reports whether the event or the participation of the owner is tentative.
+func tentative(ev *Event) bool {
+	if ev.Status == StatusTentative {
+		return true
+	}
+	for _, attendee := range ev.Attendees {
+		if attendee.Self && attendee.ResponseStatus == ResponseTentative {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeFreeBusyPeriods sorts the periods and merges the ones that overlap or touch.
+func mergeFreeBusyPeriods(periods []freeBusyPeriod) []freeBusyPeriod {
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].start.Before(periods[j].start)
+	})
+	var merged []freeBusyPeriod
+	for _, p := range periods {
+		if n := len(merged); n > 0 && !p.start.After(merged[n-1].end) {
+			if p.end.After(merged[n-1].end) {
+				merged[n-1].end = p.end
+			}
+			continue
+		}
+		merged = append(merged, p)
+	}
+	return merged
+}
+
+// GoogleFreeBusySource reads the busy time of the calendar with Google's freebusy.query, using the Client of the config.
+// It only needs free/busy access to the calendar, so it also works for calendars of other users, e.g. by their
+// CalendarID. Every busy period is returned as an opaque event without any details.
+type GoogleFreeBusySource struct{}
+
+// Fetch implements Source.
+func (GoogleFreeBusySource) Fetch(ctx context.Context, config *Config) (*Calendar, []Event, error) {
+	config.Logger.Debug().Msg("getting calendar service")
+	service, err := calendar.NewService(ctx, option.WithHTTPClient(config.Client))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to create calendar service")
+	}
+
+	cal := &Calendar{ID: config.CalendarID, Summary: config.CalendarID}
+	if config.CalendarID == "" {
+		config.Logger.Debug().Str("calendar", config.CalendarName).Msg("finding calendar id")
+		entry, err := findCalendar(ctx, service, config.CalendarName)
+		if err != nil {
+			return nil, nil, err
+		}
+		cal = &Calendar{ID: entry.Id, Summary: entry.Summary, TimeZone: entry.TimeZone}
+	}
+
+	config.Logger.Debug().Str("calendar_id", cal.ID).Msg("querying free/busy time")
+	resp, err := service.Freebusy.Query(&calendar.FreeBusyRequest{
+		TimeMin:  config.StartFrom.Format(time.RFC3339),
+		TimeMax:  config.EndOn.Format(time.RFC3339),
+		TimeZone: cal.TimeZone,
+		Items:    []*calendar.FreeBusyRequestItem{{Id: cal.ID}},
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to query free/busy time of calendar `%s'", cal.ID)
+	}
+	fb, ok := resp.Calendars[cal.ID]
+	if !ok {
+		return nil, nil, errors.Errorf("no free/busy time for calendar `%s'", cal.ID)
+	}
+	if len(fb.Errors) > 0 {
+		return nil, nil, errors.Errorf("unable to query free/busy time of calendar `%s': %s", cal.ID, fb.Errors[0].Reason)
+	}
+
+	events := make([]Event, 0, len(fb.Busy))
+	for _, period := range fb.Busy {
+		if period == nil {
+			continue
+		}
+		start, err := time.Parse(time.RFC3339, period.Start)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to parse start `%s'", period.Start)
+		}
+		end, err := time.Parse(time.RFC3339, period.End)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to parse end `%s'", period.End)
+		}
+		sum := sha256.Sum256([]byte(cal.ID + "/" + period.Start + "/" + period.End))
+		events = append(events, Event{
+			ID:           hex.EncodeToString(sum[:8]),
+			UID:          hex.EncodeToString(sum[:16]) + "@gcal-to-ics",
+			Summary:      defaultBusyLabel,
+			Start:        EventTime{Time: start, TimeZone: cal.TimeZone},
+			End:          EventTime{Time: end, TimeZone: cal.TimeZone},
+			Status:       StatusConfirmed,
+			Transparency: TransparencyOpaque,
+		})
+	}
+	config.Logger.Debug().
+		Str("calendar_id", cal.ID).
+		Msgf("found %d busy periods", len(events))
+	return cal, events, nil
+}
@@ -0,0 +1,106 @@
+package gti
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreeBusy(t *testing.T) {
+	var buf bytes.Buffer
+	config := &Config{
+		Format:    "ifb",
+		Logger:    nopLogger(),
+		Writer:    &buf,
+		Version:   "test",
+		StartFrom: time.Date(2024, time.May, 1, 9, 0, 0, 0, time.UTC),
+		EndOn:     time.Date(2024, time.May, 2, 9, 0, 0, 0, time.UTC),
+	}
+
+	at := func(hour, min int) EventTime {
+		return EventTime{Time: time.Date(2024, time.May, 1, hour, min, 0, 0, time.UTC)}
+	}
+	events := []Event{
+		{Summary: "Early", Start: at(8, 0), End: at(9, 30)},
+		{Summary: "Overlapping", Start: at(9, 15), End: at(10, 0)},
+		{Summary: "Adjacent", Start: at(10, 0), End: at(10, 30)},
+		{Summary: "Maybe", Start: at(11, 0), End: at(12, 0), Status: StatusTentative},
+		{Summary: "Lunch", Start: at(12, 0), End: at(13, 0), Transparency: TransparencyTransparent},
+		{
+			Summary:   "Declined",
+			Start:     at(14, 0),
+			End:       at(15, 0),
+			Attendees: []Attendee{{Person: Person{Email: "me@example.com"}, Self: true, ResponseStatus: ResponseDeclined}},
+		},
+		{Summary: "Out of range", Start: at(6, 0), End: at(7, 0)},
+	}
+	require.NoError(t, Write(config, &Calendar{ID: "work", Summary: "Work"}, events))
+
+	out := buf.String()
+	require.Contains(t, out, "FREEBUSY;FBTYPE=BUSY:20240501T090000Z/20240501T103000Z\r\n")
+	require.Contains(t, out, "FREEBUSY;FBTYPE=BUSY-TENTATIVE:20240501T110000Z/20240501T120000Z\r\n")
+	require.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("FREEBUSY;")))
+	require.NotContains(t, out, "VEVENT")
+
+	// the account is the organizer, unless the people of the events are hidden
+	for _, tt := range []struct {
+		hide      HideFields
+		privacy   string
+		organizer bool
+	}{
+		{organizer: true},
+		{hide: HideFields{Organizer: true}},
+		{hide: HideFields{Attendees: true}},
+		{privacy: PrivacyBusy},
+	} {
+		buf.Reset()
+		cfg := *config
+		cfg.AccountEmail = "me@example.com"
+		cfg.HideFields = tt.hide
+		cfg.Privacy = tt.privacy
+		require.NoError(t, Write(&cfg, &Calendar{ID: "work", Summary: "Work"}, events))
+		require.Equal(t, tt.organizer, strings.Contains(buf.String(), "ORGANIZER;CN=me@example.com:mailto:me@example.com\r\n"))
+		require.Equal(t, tt.organizer, strings.Contains(buf.String(), "me@example.com"))
+	}
+
+	config.KeepRecurrence = true
+	events = []Event{{ID: "series", Summary: "Daily", Start: at(9, 0), End: at(10, 0), Recurrence: []string{"RRULE:FREQ=DAILY"}}}
+	require.Error(t, Write(config, &Calendar{ID: "work"}, events))
+}
+
+func TestGoogleFreeBusySource(t *testing.T) {
+	server := newFakeServer(t, "calendar.json")
+	config := &Config{
+		Logger:     nopLogger(),
+		StartFrom:  time.Date(2024, time.May, 6, 0, 0, 0, 0, time.UTC),
+		EndOn:      time.Date(2024, time.May, 13, 0, 0, 0, 0, time.UTC),
+		CalendarID: "work@example.com",
+		Client:     server.Client(),
+	}
+	cal, events, err := GoogleFreeBusySource{}.Fetch(context.Background(), config)
+	require.NoError(t, err)
+	require.Equal(t, "work@example.com", cal.ID)
+
+	// the transparent holiday is not busy, the untitled event is
+	require.Len(t, events, 3)
+	for _, ev := range events {
+		require.Equal(t, defaultBusyLabel, ev.Summary)
+		require.Equal(t, TransparencyOpaque, ev.Transparency)
+		require.Empty(t, ev.Description)
+	}
+	require.Equal(t, time.Date(2024, time.May, 6, 8, 0, 0, 0, time.UTC), events[0].Start.Time.UTC())
+
+	config.CalendarID = ""
+	config.CalendarName = "Work"
+	cal, _, err = GoogleFreeBusySource{}.Fetch(context.Background(), config)
+	require.NoError(t, err)
+	require.Equal(t, "Europe/Berlin", cal.TimeZone)
+
+	config.CalendarID = "missing@example.com"
+	_, _, err = GoogleFreeBusySource{}.Fetch(context.Background(), config)
+	require.EqualError(t, err, "unable to query free/busy time of calendar `missing@example.com': notFound")
+}
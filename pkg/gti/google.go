@@ -0,0 +1,426 @@
+package gti
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Eun/gcal-to-ics/pkg/ical"
+	"github.com/pkg/errors"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+const googleDateFormat = "2006-01-02"
+const maxCalendarsToFetchPerAPICall = 100
+const maxEventsToFetchPerAPICall = 100
+
+// fetchGoogle fetches the calendar and its events from the Google Calendar API.
+func fetchGoogle(ctx context.Context, config *Config) (*Calendar, []Event, error) {
+	config.Logger.Debug().Msg("getting calendar service")
+	service, err := calendar.NewService(ctx, option.WithHTTPClient(config.Client))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to create calendar service")
+	}
+
+	var entry *calendar.CalendarListEntry
+	if config.CalendarID != "" {
+		config.Logger.Debug().Str("calendar_id", config.CalendarID).Msg("getting calendar")
+		entry, err = service.CalendarList.Get(config.CalendarID).Context(ctx).Do()
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to get calendar `%s'", config.CalendarID)
+		}
+	} else {
+		config.Logger.Debug().Str("calendar", config.CalendarName).Msg("finding calendar id")
+		entry, err = findCalendar(ctx, service, config.CalendarName)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	config.Logger.Debug().
+		Str("calendar", config.CalendarName).
+		Str("calendar_id", entry.Id).
+		Msg("found calendar id")
+
+	// get some details about the calendar
+	config.Logger.Debug().Str("calendar_id", entry.Id).Msg("getting calendar details")
+	cal, err := service.Calendars.Get(entry.Id).Context(ctx).Do()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to get details for calendar `%s'", entry.Id)
+	}
+
+	var items []*calendar.Event
+	if config.StoreDir != "" {
+		items, err = syncEvents(ctx, service, entry.Id, config)
+	} else {
+		items, _, err = fetchEvents(ctx, service, entry.Id, config, eventsQuery{
+			timeMin: config.StartFrom,
+			timeMax: config.EndOn,
+		})
+	}
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	if config.KeepRecurrence {
+		addCancelledInstances(items, cal.TimeZone)
+	}
+
+	events := make([]Event, 0, len(items))
+	for _, item := range items {
+		if item.Id == "" || item.Start == nil || item.End == nil || strings.EqualFold(item.Status, "cancelled") {
+			continue
+		}
+		events = append(events, convertGoogleEvent(item, cal.TimeZone, entry.DefaultReminders, config.AccountEmail))
+	}
+
+	return &Calendar{
+		ID:          cal.Id,
+		Summary:     cal.Summary,
+		Description: cal.Description,
+		TimeZone:    cal.TimeZone,
+	}, events, nil
+}
+
+// findCalendar returns the calendar that is selected by name, deleted calendars are ignored.
+// name is matched against the summary of the calendars, if no summary equals name,
+// `primary' selects the primary calendar, a name between slashes (/^Team/) is a regular expression
+// and a name with *, ? or [ is a glob pattern.
+// It fails if no calendar or more than one calendar matches, listing the candidates.
+func findCalendar(ctx context.Context, service *calendar.Service, name string) (*calendar.CalendarListEntry, error) {
+	entries, err := listCalendars(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := filterCalendars(entries, func(entry *calendar.CalendarListEntry) bool {
+		return entry.Summary == name
+	})
+	if len(matches) == 0 {
+		match, err := calendarMatcher(name)
+		if err != nil {
+			return nil, err
+		}
+		if match != nil {
+			matches = filterCalendars(entries, match)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, errors.Errorf("no such calendar `%s', candidates: %s", name, calendarCandidates(entries))
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, errors.Errorf("calendar `%s' is ambiguous, candidates: %s", name, calendarCandidates(matches))
+	}
+}
+
+// calendarMatcher returns the matcher for the primary alias, a regular expression or a glob pattern,
+// it returns nil if name is neither of them.
+func calendarMatcher(name string) (func(entry *calendar.CalendarListEntry) bool, error) {
+	switch {
+	case name == "primary":
+		return func(entry *calendar.CalendarListEntry) bool {
+			return entry.Primary
+		}, nil
+	case len(name) > 2 && strings.HasPrefix(name, "/") && strings.HasSuffix(name, "/"):
+		re, err := regexp.Compile(name[1 : len(name)-1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid calendar regex `%s'", name)
+		}
+		return func(entry *calendar.CalendarListEntry) bool {
+			return re.MatchString(entry.Summary)
+		}, nil
+	case strings.ContainsAny(name, "*?["):
+		if _, err := path.Match(name, ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid calendar pattern `%s'", name)
+		}
+		return func(entry *calendar.CalendarListEntry) bool {
+			ok, _ := path.Match(name, entry.Summary)
+			return ok
+		}, nil
+	}
+	return nil, nil
+}
+
+// listCalendars returns all calendars of the account that are not deleted, including the hidden ones.
+func listCalendars(ctx context.Context, service *calendar.Service) ([]*calendar.CalendarListEntry, error) {
+	var entries []*calendar.CalendarListEntry
+	var nextPageToken string
+	for {
+		callCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		call := service.CalendarList.List().
+			MaxResults(maxCalendarsToFetchPerAPICall).
+			ShowHidden(true).
+			Context(callCtx)
+		if nextPageToken != "" {
+			call.PageToken(nextPageToken)
+		}
+
+		list, err := call.Do()
+		cancel()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to list calendars")
+		}
+
+		if list == nil {
+			return nil, errors.New("list is nil")
+		}
+
+		for i := range list.Items {
+			if !list.Items[i].Deleted {
+				entries = append(entries, list.Items[i])
+			}
+		}
+
+		if list.NextPageToken == "" {
+			break
+		}
+		nextPageToken = list.NextPageToken
+	}
+	return entries, nil
+}
+
+func filterCalendars(
+	entries []*calendar.CalendarListEntry,
+	match func(entry *calendar.CalendarListEntry) bool,
+) []*calendar.CalendarListEntry {
+	var result []*calendar.CalendarListEntry
+	for _, entry := range entries {
+		if match(entry) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// calendarCandidates lists the summaries and ids of the calendars for error messages.
+func calendarCandidates(entries []*calendar.CalendarListEntry) string {
+	if len(entries) == 0 {
+		return "none"
+	}
+	candidates := make([]string, len(entries))
+	for i, entry := range entries {
+		candidates[i] = fmt.Sprintf("%q (%s)", entry.Summary, entry.Id)
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// eventsQuery selects the events that are listed, either by a time range or by a sync token.
+type eventsQuery struct {
+	timeMin   time.Time
+	timeMax   time.Time
+	syncToken string
+}
+
+// fetchEvents lists all events of the query, it also returns the token for the next incremental sync.
+func fetchEvents(
+	ctx context.Context,
+	service *calendar.Service,
+	calendarID string,
+	config *Config,
+	query eventsQuery,
+) ([]*calendar.Event, string, error) {
+	var events []*calendar.Event
+	var nextPageToken string
+	for {
+		config.Logger.Debug().
+			Str("calendar_id", calendarID).
+			Time("time_min", query.timeMin).
+			Time("time_max", query.timeMax).
+			Bool("sync", query.syncToken != "").
+			Bool("keep_recurrence", config.KeepRecurrence).
+			Str("next_page_token", nextPageToken).
+			Msg("finding events")
+
+		callCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		call := service.Events.List(calendarID).
+			MaxResults(maxEventsToFetchPerAPICall).
+			SingleEvents(!config.KeepRecurrence).
+			Context(callCtx)
+		if query.syncToken != "" {
+			call.SyncToken(query.syncToken)
+		} else {
+			call.ShowDeleted(false)
+		}
+		if !query.timeMin.IsZero() {
+			call.TimeMin(query.timeMin.Format(time.RFC3339))
+		}
+		if !query.timeMax.IsZero() {
+			call.TimeMax(query.timeMax.Format(time.RFC3339))
+		}
+		if nextPageToken != "" {
+			call.PageToken(nextPageToken)
+		}
+
+		list, err := call.Do()
+		cancel()
+		if err != nil {
+			return nil, "", errors.Wrap(err, "unable to list events")
+		}
+
+		if list == nil {
+			return nil, "", errors.New("list is nil")
+		}
+
+		config.Logger.Debug().Msgf("found %d items", len(list.Items))
+
+		events = append(events, list.Items...)
+
+		if list.NextPageToken == "" {
+			return events, list.NextSyncToken, nil
+		}
+		nextPageToken = list.NextPageToken
+	}
+}
+
+// addCancelledInstances adds an EXDATE line to the recurring events for every cancelled instance.
+// Google reports cancelled instances of a recurring event as separate events without a summary,
+// so they would be lost otherwise.
+func addCancelledInstances(events []*calendar.Event, calendarTimeZone string) {
+	recurringEvents := make(map[string]*calendar.Event)
+	for _, ev := range events {
+		if len(ev.Recurrence) > 0 {
+			recurringEvents[ev.Id] = ev
+		}
+	}
+
+	for _, ev := range events {
+		if ev.RecurringEventId == "" || ev.OriginalStartTime == nil || !strings.EqualFold(ev.Status, "cancelled") {
+			continue
+		}
+		recurringEvent, ok := recurringEvents[ev.RecurringEventId]
+		if !ok {
+			continue
+		}
+		timeZone := calendarTimeZone
+		if recurringEvent.Start != nil && recurringEvent.Start.TimeZone != "" {
+			timeZone = recurringEvent.Start.TimeZone
+		}
+		t := convertGoogleEventTime(ev.OriginalStartTime, timeZone)
+		if t.IsZero() {
+			continue
+		}
+		recurringEvent.Recurrence = append(recurringEvent.Recurrence, ical.FormatProperty(eventTimeProperty("EXDATE", t)))
+	}
+}
+
+func convertGoogleEventTime(dt *calendar.EventDateTime, defaultTimeZone string) EventTime {
+	timeZone := dt.TimeZone
+	if timeZone == "" {
+		timeZone = defaultTimeZone
+	}
+	if dt.Date != "" {
+		t, err := time.Parse(googleDateFormat, dt.Date)
+		if err != nil {
+			return EventTime{}
+		}
+		return EventTime{Time: t, AllDay: true, TimeZone: timeZone}
+	}
+	t, err := time.Parse(time.RFC3339, dt.DateTime)
+	if err != nil {
+		return EventTime{}
+	}
+	return EventTime{Time: t, TimeZone: timeZone}
+}
+
+var googleResponseStatuses = map[string]string{
+	"needsAction": ResponseNeedsAction,
+	"declined":    ResponseDeclined,
+	"tentative":   ResponseTentative,
+	"accepted":    ResponseAccepted,
+}
+
+func convertGoogleEvent(
+	item *calendar.Event,
+	calendarTimeZone string,
+	defaultReminders []*calendar.EventReminder,
+	accountEmail string,
+) Event {
+	ev := Event{
+		ID:               item.Id,
+		UID:              item.ICalUID,
+		Summary:          item.Summary,
+		Description:      item.Description,
+		Location:         item.Location,
+		Start:            convertGoogleEventTime(item.Start, calendarTimeZone),
+		Recurrence:       item.Recurrence,
+		RecurringEventID: item.RecurringEventId,
+		Status:           strings.ToUpper(item.Status),
+		Transparency:     strings.ToUpper(item.Transparency),
+		HTMLLink:         item.HtmlLink,
+		ColorID:          item.ColorId,
+		EventType:        item.EventType,
+	}
+	ev.End = convertGoogleEventTime(item.End, ev.Start.TimeZone)
+	if item.OriginalStartTime != nil {
+		t := convertGoogleEventTime(item.OriginalStartTime, ev.Start.TimeZone)
+		ev.RecurrenceID = &t
+	}
+
+	switch strings.ToUpper(item.Visibility) {
+	case VisibilityPublic:
+		ev.Visibility = VisibilityPublic
+	case VisibilityPrivate:
+		ev.Visibility = VisibilityPrivate
+	}
+
+	if item.Organizer != nil && item.Organizer.Email != "" {
+		ev.Organizer = &Person{Email: item.Organizer.Email, DisplayName: item.Organizer.DisplayName}
+	}
+
+	for _, attendee := range item.Attendees {
+		if attendee == nil || attendee.Email == "" {
+			continue
+		}
+		ev.Attendees = append(ev.Attendees, Attendee{
+			Person:         Person{Email: attendee.Email, DisplayName: attendee.DisplayName},
+			Optional:       attendee.Optional,
+			ResponseStatus: googleResponseStatuses[attendee.ResponseStatus],
+			Self:           attendee.Self || strings.EqualFold(attendee.Email, accountEmail),
+		})
+	}
+
+	if item.ConferenceData != nil {
+		for _, point := range item.ConferenceData.EntryPoints {
+			if point == nil || point.Uri == "" {
+				continue
+			}
+			ev.ConferenceURI = point.Uri
+			break
+		}
+	}
+
+	if item.Reminders != nil {
+		reminders := item.Reminders.Overrides
+		if item.Reminders.UseDefault {
+			reminders = defaultReminders
+		}
+		for _, reminder := range reminders {
+			if reminder == nil {
+				continue
+			}
+			method := ReminderDisplay
+			if strings.EqualFold(reminder.Method, "email") {
+				method = ReminderEmail
+			}
+			ev.Reminders = append(ev.Reminders, Reminder{
+				Method: method,
+				Before: time.Duration(reminder.Minutes) * time.Minute,
+			})
+		}
+	}
+
+	if created, err := time.Parse(time.RFC3339, item.Created); err == nil {
+		ev.Created = created
+	}
+	if updated, err := time.Parse(time.RFC3339, item.Updated); err == nil {
+		ev.Updated = updated
+	}
+	return ev
+}
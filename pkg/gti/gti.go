@@ -2,30 +2,55 @@ package gti
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 
+	"github.com/Eun/gcal-to-ics/pkg/ical"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
-	"google.golang.org/api/calendar/v3"
-	"google.golang.org/api/option"
 )
 
 type Config struct {
-	Format          string
-	AccountEmail    string
-	Logger          *zerolog.Logger
-	StartFrom       time.Time
-	EndOn           time.Time
-	CalendarName    string
+	Format       string
+	AccountEmail string
+	Logger       *zerolog.Logger
+	StartFrom    time.Time
+	EndOn        time.Time
+	CalendarName string
+	// CalendarID selects the Google calendar by its id instead of the CalendarName, primary is the primary calendar.
+	CalendarID      string
 	Writer          io.Writer
 	Client          *http.Client
 	Version         string
 	HideFields      HideFields
 	OverwriteFields OverwriteFields
+	// KeepRecurrence writes recurring events as a single series (RRULE, RDATE, EXDATE)
+	// with modified instances carrying a RECURRENCE-ID instead of expanding every instance.
+	KeepRecurrence bool
+	// Source provides the calendar and its events, if nil the GoogleSource is used.
+	Source Source
+	// StoreDir enables incremental syncs of the GoogleSource, the events and the sync token of every
+	// calendar are stored in this directory.
+	StoreDir string
+	// SnapshotDir is the directory the fetched calendar and events are saved in after every successful fetch.
+	SnapshotDir string
+	// Offline reads the calendar and events from the snapshot in SnapshotDir instead of the Source.
+	Offline bool
+	// Privacy is one of the Privacy constants.
+	// In the busy mode transparent and declined events are dropped, and the other events only show
+	// the BusyLabel (Busy by default) at their time.
+	Privacy   string
+	BusyLabel string
+	// BusySlot rounds the start and end of single events outwards to multiples of the slot in the busy mode.
+	BusySlot time.Duration
+	// Filter selects the events that are exported.
+	Filter Filter
+	// TemplateFields rewrite fields of the events with templates, after the hidden and overwritten fields are applied.
+	TemplateFields TemplateFields
+	// Redaction removes sensitive data from the summary, description and location of the events,
+	// after the template fields are applied.
+	Redaction Redaction
 }
 
 type HideFields struct {
@@ -38,10 +63,12 @@ type HideFields struct {
 	Conference   bool `yaml:"conference" json:"conference,omitempty"`
 	Transparency bool `yaml:"transparency" json:"transparency,omitempty"`
 	Status       bool `yaml:"status" json:"status,omitempty"`
+	Reminders    bool `yaml:"reminders" json:"reminders,omitempty"`
 }
 
 type OverwriteFields struct {
 	CalendarName string `yaml:"calendar_name" json:"calendar_name,omitempty"`
+	Summary      string `yaml:"summary" json:"summary,omitempty"`
 	Organizer    string `yaml:"organizer" json:"organizer,omitempty"`
 	Visibility   string `yaml:"visibility" json:"visibility,omitempty"`
 	Description  string `yaml:"description" json:"description,omitempty"`
@@ -49,402 +76,160 @@ type OverwriteFields struct {
 	Conference   string `yaml:"conference" json:"conference,omitempty"`
 	Transparency string `yaml:"transparency" json:"transparency,omitempty"`
 	Status       string `yaml:"status" json:"status,omitempty"`
+	// Alarm replaces the reminders of every event with a single alarm the specified duration before the event.
+	Alarm time.Duration `yaml:"alarm" json:"alarm,omitempty"`
 }
 
+// Export fetches the calendar and writes it in the configured format to the Writer of the config.
 func Export(config *Config) error {
 	if config == nil {
 		return errors.New("config cannot be nil")
 	}
-	if config.Format != "ics" {
+	if _, ok := LookupFormat(config.Format); !ok {
 		return errors.Errorf("format `%s' is not supported", config.Format)
 	}
 
-	config.Logger.Debug().Msg("getting calendar service")
-	service, err := calendar.NewService(context.Background(), option.WithHTTPClient(config.Client))
+	cal, events, err := Fetch(context.Background(), config)
 	if err != nil {
-		return errors.Wrap(err, "unable to create calendar service")
+		return err
 	}
-
-	config.Logger.Debug().Str("calendar", config.CalendarName).Msg("finding calendar id")
-	calendarID, err := findCalendarID(service, config.CalendarName)
-	if err != nil {
-		return errors.Wrapf(err, "unable to find calendar id for `%s'", config.CalendarName)
-	}
-	if calendarID == "" {
-		return errors.Errorf("no such calendar `%s'", config.CalendarName)
-	}
-
-	config.Logger.Debug().
-		Str("calendar", config.CalendarName).
-		Str("calendar_id", calendarID).
-		Msg("found calendar id")
-
-	return writeEvents(service, calendarID, config)
+	return Write(config, cal, events)
 }
 
-func findCalendarID(service *calendar.Service, calendarName string) (string, error) {
-	var nextPageToken string
-	for {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-		call := service.CalendarList.List().
-			MaxResults(maxCalendarsToFetchPerAPICall).
-			ShowHidden(true).
-			Context(ctx)
-		if nextPageToken != "" {
-			call.PageToken(nextPageToken)
-		}
-
-		list, err := call.Do()
-		cancel()
-		if err != nil {
-			return "", errors.Wrap(err, "unable to list calendars")
-		}
-
-		if list == nil {
-			return "", errors.New("list is nil")
-		}
-
-		for i := range list.Items {
-			if list.Items[i].Deleted {
-				continue
-			}
-			if list.Items[i].Summary == calendarName {
-				return list.Items[i].Id, nil
-			}
-		}
-
-		if list.NextPageToken == "" {
-			break
-		}
-		nextPageToken = list.NextPageToken
+// Fetch fetches the calendar and its events in the configured time range from the Source of the config.
+// The hidden and overwritten fields are not applied, use Write to write the result.
+func Fetch(ctx context.Context, config *Config) (*Calendar, []Event, error) {
+	if config == nil {
+		return nil, nil, errors.New("config cannot be nil")
 	}
-	return "", nil
-}
-
-const icalTimestampFormatUtc = "20060102T150405Z"
-const icalDateFormatUtc = "20060102"
-const googleDateFormat = "2006-01-02"
-const maxCalendarsToFetchPerAPICall = 100
-const maxEventsToFetchPerAPICall = 100
-
-var textEscaper = strings.NewReplacer(
-	`\`, `\\`,
-	"\n", `\n`,
-	`;`, `\;`,
-	`,`, `\,`,
-)
-
-func toText(s string) string {
-	// Some special characters for iCalendar format should be escaped while
-	// setting a value of a property with a TEXT type.
-	return textEscaper.Replace(s)
-}
-
-func writeHeader(config *Config, cal *calendar.Calendar) error {
-	// write header
-	for _, s := range []string{
-		"BEGIN:VCALENDAR",
-		"VERSION:2.0",
-		"PRODID:-//gcal-to-ics//gcal-to-ics-" + config.Version + "//EN",
-		"CALSCALE:GREGORIAN",
-		"METHOD:PUBLISH",
-		"X-WR-TIMEZONE:" + cal.TimeZone,
-	} {
-		if _, err := fmt.Fprint(config.Writer, s, "\n"); err != nil {
-			return errors.WithStack(err)
+	if config.Offline {
+		if config.SnapshotDir == "" {
+			return nil, nil, errors.New("offline mode needs a snapshot dir")
 		}
+		return readSnapshot(config)
 	}
 
-	calendarName := cal.Summary
-	if config.OverwriteFields.CalendarName != "" {
-		calendarName = config.OverwriteFields.CalendarName
+	source := config.Source
+	if source == nil {
+		source = GoogleSource{}
 	}
-
-	if _, err := fmt.Fprint(config.Writer, "X-WR-CALNAME:", toText(calendarName), "\n"); err != nil {
-		return errors.WithStack(err)
+	cal, events, err := source.Fetch(ctx, config)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	return nil
-}
-
-func writeTrailer(config *Config) error {
-	// write header
-	for _, s := range []string{
-		"END:VCALENDAR",
-	} {
-		if _, err := fmt.Fprint(config.Writer, s, "\n"); err != nil {
-			return errors.WithStack(err)
+	if config.SnapshotDir != "" {
+		if err := writeSnapshot(config, cal, events); err != nil {
+			return nil, nil, errors.Wrap(err, "unable to write snapshot")
 		}
 	}
-	return nil
+	return cal, events, nil
 }
 
-func writeEvent(config *Config, ev *calendar.Event) error {
-	fmt.Fprintf(config.Writer, "BEGIN:VEVENT\n")
-
-	if !config.HideFields.UID {
-		fmt.Fprintf(config.Writer, "UID:%s\n", ev.ICalUID)
-	}
-
-	if err := writeEventTime(config, ev); err != nil {
-		return errors.WithStack(err)
-	}
-
-	fmt.Fprintf(config.Writer, "SUMMARY:%s\n", toText(ev.Summary))
-	if !config.HideFields.Description {
-		if config.OverwriteFields.Description != "" {
-			ev.Description = config.OverwriteFields.Description
-		}
-		if ev.Description != "" {
-			fmt.Fprintf(config.Writer, "DESCRIPTION:%s\n", toText(ev.Description))
-		}
-	}
-
-	if !config.HideFields.Transparency {
-		if config.OverwriteFields.Transparency != "" {
-			ev.Transparency = config.OverwriteFields.Transparency
-		}
-		if strings.EqualFold(ev.Transparency, "TRANSPARENT") {
-			fmt.Fprint(config.Writer, "TRANSP:TRANSPARENT\n")
-		} else {
-			fmt.Fprint(config.Writer, "TRANSP:OPAQUE\n")
-		}
-	}
-
-	if !config.HideFields.Location {
-		if config.OverwriteFields.Location != "" {
-			ev.Location = config.OverwriteFields.Location
-		}
-		if ev.Location != "" {
-			fmt.Fprintf(config.Writer, "LOCATION:%s\n", toText(ev.Location))
-		}
-	}
-
-	if !config.HideFields.Visibility {
-		if config.OverwriteFields.Visibility != "" {
-			ev.Visibility = config.OverwriteFields.Visibility
-		}
-		switch strings.ToUpper(ev.Visibility) {
-		case "PUBLIC":
-			fmt.Fprintf(config.Writer, "CLASS:%s\n", toText("PUBLIC"))
-		case "PRIVATE":
-			fmt.Fprintf(config.Writer, "CLASS:%s\n", toText("PRIVATE"))
-		}
-	}
-
-	if !config.HideFields.Conference {
-		if config.OverwriteFields.Conference != "" {
-			fmt.Fprintf(config.Writer, "X-GOOGLE-CONFERENCE:%s\n", toText(config.OverwriteFields.Conference))
-		} else if ev.ConferenceData != nil && len(ev.ConferenceData.EntryPoints) > 0 {
-			for _, point := range ev.ConferenceData.EntryPoints {
-				if point.Uri == "" {
-					continue
-				}
-				fmt.Fprintf(config.Writer, "X-GOOGLE-CONFERENCE:%s\n", toText(point.Uri))
-				break
-			}
-		}
-	}
-
-	if !config.HideFields.Organizer {
-		if config.OverwriteFields.Organizer != "" {
-			fmt.Fprintf(config.Writer, "ORGANIZER:%s\n", toText(config.OverwriteFields.Organizer))
-		} else if ev.Organizer != nil && ev.Organizer.Email != "" {
-			displayName := ev.Organizer.DisplayName
-			if displayName == "" {
-				displayName = ev.Organizer.Email
-			}
-			fmt.Fprintf(config.Writer, "ORGANIZER:CN=%s:mailto:%s\n", displayName, ev.Organizer.Email)
-		}
-	}
-
-	if !config.HideFields.Attendees {
-		for _, attendee := range ev.Attendees {
-			if attendee == nil || attendee.Email == "" {
-				continue
-			}
-
-			role := "REQ-PARTICIPANT"
-			if attendee.Optional {
-				role = "OPT-PARTICIPANT"
-			}
-			var partStat string
-			switch attendee.ResponseStatus {
-			case "needsAction":
-				partStat = "NEEDS-ACTION"
-			case "declined":
-				partStat = "DECLINED"
-			case "tentative":
-				partStat = "TENTATIVE"
-			case "accepted":
-				partStat = "ACCEPTED"
-			}
-
-			// overwrite status if we are an attendee
-			if strings.EqualFold(attendee.Email, config.AccountEmail) {
-				ev.Status = attendee.ResponseStatus
-			}
-
-			displayName := attendee.DisplayName
-			if displayName == "" {
-				displayName = attendee.Email
-			}
-
-			fmt.Fprintf(config.Writer, "ATTENDEE;ROLE=%s;PARTSTAT=%s;CN=%s:mailto:%s",
-				role,
-				partStat,
-				displayName,
-				attendee.Email,
-			)
-		}
-	}
-
-	if !config.HideFields.Status {
-		if config.OverwriteFields.Status != "" {
-			ev.Status = config.OverwriteFields.Status
-		}
-
-		switch strings.ToUpper(ev.Status) {
-		case "TENTATIVE":
-			fmt.Fprint(config.Writer, "STATUS:TENTATIVE\n")
-		case "CANCELLED": //nolint: misspell // not a misspell
-			fmt.Fprint(config.Writer, "STATUS:CANCELLED\n") //nolint: misspell // not a misspell
-		case "CONFIRMED":
-			fmt.Fprint(config.Writer, "STATUS:CONFIRMED\n")
-		}
+// Write applies the filter, the hidden, overwritten and template fields and the redaction to the calendar and
+// events and writes them in the configured format to the Writer of the config.
+// The passed calendar and events are not modified.
+func Write(config *Config, cal *Calendar, events []Event) error {
+	if config == nil {
+		return errors.New("config cannot be nil")
 	}
-
-	created, err := time.Parse(time.RFC3339, ev.Created)
-	if err == nil {
-		fmt.Fprintf(config.Writer, "DTSTAMP:%s\n", created.UTC().Format(icalTimestampFormatUtc))
-		fmt.Fprintf(config.Writer, "CREATED:%s\n", created.UTC().Format(icalTimestampFormatUtc))
+	if cal == nil {
+		return errors.New("calendar cannot be nil")
 	}
-	updated, err := time.Parse(time.RFC3339, ev.Updated)
-	if err == nil {
-		fmt.Fprintf(config.Writer, "LAST-MODIFIED:%s\n", updated.UTC().Format(icalTimestampFormatUtc))
+	format, ok := LookupFormat(config.Format)
+	if !ok {
+		return errors.Errorf("format `%s' is not supported", config.Format)
 	}
-	fmt.Fprintf(config.Writer, "END:VEVENT\n")
-	return nil
-}
-
-func writeEventTime(config *Config, ev *calendar.Event) error {
-	if ev.Start.Date != "" && ev.End.Date != "" {
-		// all day event
-		return writeAllDayEventTime(config, ev)
+	switch config.Privacy {
+	case PrivacyFull, PrivacyBusy:
+	default:
+		return errors.Errorf("privacy `%s' is not supported", config.Privacy)
 	}
-	startTime, err := time.Parse(time.RFC3339, ev.Start.DateTime)
+	filter, err := newEventFilter(&config.Filter)
 	if err != nil {
-		startTime = time.Time{}
+		return errors.Wrap(err, "invalid filter")
 	}
-	endTime, err := time.Parse(time.RFC3339, ev.End.DateTime)
+	templates, err := newFieldTemplates(&config.TemplateFields)
 	if err != nil {
-		endTime = time.Time{}
+		return errors.Wrap(err, "invalid template fields")
 	}
-
-	if !startTime.IsZero() && !endTime.IsZero() {
-		_, err = fmt.Fprintf(config.Writer, "DTSTART:%s\n", startTime.UTC().Format(icalTimestampFormatUtc))
-		if err != nil {
-			return errors.WithStack(err)
-		}
-		_, err = fmt.Fprintf(config.Writer, "DTEND:%s\n", endTime.UTC().Format(icalTimestampFormatUtc))
-		if err != nil {
-			return errors.WithStack(err)
-		}
-	}
-	return nil
-}
-
-func writeAllDayEventTime(config *Config, ev *calendar.Event) error {
-	startTime, err := time.Parse(googleDateFormat, ev.Start.Date)
-	if err != nil {
-		startTime = time.Time{}
-	}
-	endTime, err := time.Parse(googleDateFormat, ev.End.Date)
+	redactor, err := newRedactor(&config.Redaction)
 	if err != nil {
-		endTime = time.Time{}
+		return errors.Wrap(err, "invalid redaction")
 	}
+	formatter := format.New(config)
 
-	if startTime.IsZero() || endTime.IsZero() {
-		return nil
+	c := *cal
+	applyCalendarFields(config, &c)
+	if config.Privacy == PrivacyBusy {
+		c.Description = ""
 	}
-	_, err = fmt.Fprintf(config.Writer, "DTSTART;VALUE=DATE:%s\n", startTime.UTC().Format(icalDateFormatUtc))
-	if err != nil {
-		return errors.WithStack(err)
+	if err := formatter.BeginCalendar(&c); err != nil {
+		return errors.Wrapf(err, "unable to begin calendar")
 	}
-	_, err = fmt.Fprintf(config.Writer, "DTEND;VALUE=DATE:%s\n", endTime.UTC().Format(icalDateFormatUtc))
-	if err != nil {
-		return errors.WithStack(err)
-	}
-	return nil
-}
 
-func writeEvents(service *calendar.Service, calendarID string, config *Config) error {
-	// get some details about the calendar
-	config.Logger.Debug().Str("calendar_id", calendarID).Msg("getting calendar details")
-	cal, err := service.Calendars.Get(calendarID).Do()
-	if err != nil {
-		return errors.Wrapf(err, "unable to get details for calendar `%s'", calendarID)
-	}
-	if err := writeHeader(config, cal); err != nil {
-		return errors.Wrapf(err, "unable to write header")
-	}
+	busyOnly := config.Privacy == PrivacyBusy || format.BusyOnly
+	exdates := excludedInstances(events, func(ev *Event) bool {
+		return (!busyOnly || busy(ev)) && filter.match(ev)
+	})
 
-	var nextPageToken string
 	var totalEvents int
-	for {
-		config.Logger.Debug().
-			Str("calendar_id", calendarID).
-			Time("start_from", config.StartFrom).
-			Time("end_on", config.EndOn).
-			Str("next_page_token", nextPageToken).
-			Msg("finding events")
-
-		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-		call := service.Events.List(calendarID).
-			MaxResults(maxEventsToFetchPerAPICall).
-			ShowDeleted(false).
-			TimeMin(config.StartFrom.Format(time.RFC3339)).
-			TimeMax(config.EndOn.Format(time.RFC3339)).
-			SingleEvents(true).
-			Context(ctx)
-		if nextPageToken != "" {
-			call.PageToken(nextPageToken)
+	for i := range events {
+		if events[i].Start.IsZero() || events[i].End.IsZero() {
+			continue
 		}
-
-		list, err := call.Do()
-		cancel()
-		if err != nil {
-			return errors.Wrap(err, "unable to list events")
+		// untitled events still block time, they are only skipped if their details are exported
+		if events[i].Summary == "" && !busyOnly {
+			continue
 		}
-
-		if list == nil {
-			return errors.New("list is nil")
+		if busyOnly && !busy(&events[i]) {
+			continue
 		}
-
-		config.Logger.Debug().Msgf("found %d items", len(list.Items))
-
-		for _, ev := range list.Items {
-			if ev.Id == "" || ev.Summary == "" || ev.Start == nil || ev.End == nil {
-				continue
-			}
-			if err := writeEvent(config, ev); err != nil {
-				return errors.Wrap(err, "unable to write event")
-			}
+		if !filter.match(&events[i]) {
+			continue
 		}
-		totalEvents += len(list.Items)
-
-		if list.NextPageToken == "" {
-			break
+		ev := events[i].clone()
+		if isSeries(ev) {
+			ev.Recurrence = append(ev.Recurrence, exdates[ev.UID]...)
+		}
+		applyEventFields(config, ev)
+		if err := templates.apply(&c, &config.HideFields, &events[i], ev); err != nil {
+			return err
+		}
+		redactor.apply(ev)
+		if config.Privacy == PrivacyBusy {
+			applyBusyPrivacy(config, ev)
+		}
+		if err := formatter.WriteEvent(ev); err != nil {
+			return errors.Wrap(err, "unable to write event")
 		}
-		nextPageToken = list.NextPageToken
+		totalEvents++
 	}
 
-	if err := writeTrailer(config); err != nil {
-		return errors.Wrapf(err, "unable to write trailer")
+	if err := formatter.EndCalendar(); err != nil {
+		return errors.Wrapf(err, "unable to end calendar")
 	}
 
 	config.Logger.Debug().
-		Str("calendar_id", calendarID).
+		Str("calendar_id", cal.ID).
 		Msgf("written %d events", totalEvents)
 	return nil
 }
+
+// isSeries reports whether the event is the master of a recurring event.
+func isSeries(ev *Event) bool {
+	return len(ev.Recurrence) > 0 && ev.RecurrenceID == nil
+}
+
+// excludedInstances returns the EXDATE lines for the modified instances that are not kept, by the UID of their
+// series. Otherwise the RRULE of the series would create them again at their original time.
+func excludedInstances(events []Event, keep func(ev *Event) bool) map[string][]string {
+	exdates := make(map[string][]string)
+	for i := range events {
+		ev := &events[i]
+		if ev.RecurrenceID == nil || ev.UID == "" || keep(ev) {
+			continue
+		}
+		exdates[ev.UID] = append(exdates[ev.UID], ical.FormatProperty(eventTimeProperty("EXDATE", *ev.RecurrenceID)))
+	}
+	return exdates
+}
@@ -0,0 +1,101 @@
+package gti
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func nopLogger() *zerolog.Logger {
+	logger := zerolog.Nop()
+	return &logger
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	config := &Config{
+		Format:  "ics",
+		Logger:  nopLogger(),
+		Writer:  &buf,
+		Version: "test",
+		HideFields: HideFields{
+			Location: true,
+		},
+		OverwriteFields: OverwriteFields{
+			CalendarName: "Work",
+		},
+	}
+
+	cal := &Calendar{ID: "work@example.com", Summary: "Calendar", TimeZone: "UTC"}
+	events := []Event{
+		{
+			ID:       "1",
+			UID:      "1@google.com",
+			Summary:  "Meeting",
+			Location: "Room 1",
+			Start:    EventTime{Time: time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC)},
+			End:      EventTime{Time: time.Date(2024, time.May, 1, 11, 0, 0, 0, time.UTC)},
+			Status:   StatusConfirmed,
+			Attendees: []Attendee{
+				{Person: Person{Email: "me@example.com"}, ResponseStatus: ResponseAccepted},
+			},
+			Reminders: []Reminder{{Method: ReminderDisplay, Before: 10 * time.Minute}},
+			Created:   time.Date(2024, time.April, 1, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			ID:      "2",
+			Summary: "Holiday",
+			Start:   EventTime{Time: time.Date(2024, time.May, 2, 0, 0, 0, 0, time.UTC), AllDay: true},
+			End:     EventTime{Time: time.Date(2024, time.May, 3, 0, 0, 0, 0, time.UTC), AllDay: true},
+		},
+		{
+			// events without a summary are skipped
+			ID:    "3",
+			Start: EventTime{Time: time.Date(2024, time.May, 4, 10, 0, 0, 0, time.UTC)},
+			End:   EventTime{Time: time.Date(2024, time.May, 4, 11, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	require.NoError(t, Write(config, cal, events))
+	require.Equal(t, `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//gcal-to-ics//gcal-to-ics-test//EN
+CALSCALE:GREGORIAN
+METHOD:PUBLISH
+X-WR-TIMEZONE:UTC
+X-WR-CALNAME:Work
+BEGIN:VEVENT
+UID:1@google.com
+DTSTART:20240501T100000Z
+DTEND:20240501T110000Z
+SUMMARY:Meeting
+TRANSP:OPAQUE
+ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=ACCEPTED;CN=me@example.com:mailto:me
+ @example.com
+STATUS:CONFIRMED
+DTSTAMP:20240401T080000Z
+CREATED:20240401T080000Z
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Meeting
+TRIGGER:-PT10M
+END:VALARM
+END:VEVENT
+BEGIN:VEVENT
+DTSTART;VALUE=DATE:20240502
+DTEND;VALUE=DATE:20240503
+SUMMARY:Holiday
+TRANSP:OPAQUE
+END:VEVENT
+END:VCALENDAR
+`, strings.ReplaceAll(buf.String(), "\r\n", "\n"))
+
+	// the passed calendar and events are not modified
+	require.Equal(t, "Calendar", cal.Summary)
+	require.Equal(t, "Room 1", events[0].Location)
+	require.Empty(t, events[1].Transparency)
+}
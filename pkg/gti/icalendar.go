@@ -0,0 +1,241 @@
+package gti
+
+import (
+	"io"
+
+	"github.com/Eun/gcal-to-ics/pkg/ical"
+	"github.com/pkg/errors"
+)
+
+// icalFormatter writes iCalendar based formats (ics, jCal and xCal).
+// The events are buffered until the end, because the timezones they use have to be written first.
+type icalFormatter struct {
+	config *Config
+	enc    ical.Writer
+	cal    *Calendar
+	events []*Event
+}
+
+func newICSFormatter(config *Config) Formatter {
+	return &icalFormatter{config: config, enc: ical.NewEncoder(config.Writer)}
+}
+
+func newJCalFormatter(config *Config) Formatter {
+	return &icalFormatter{config: config, enc: ical.NewJCalEncoder(config.Writer)}
+}
+
+func newXCalFormatter(config *Config) Formatter {
+	return &icalFormatter{config: config, enc: ical.NewXCalEncoder(config.Writer)}
+}
+
+// NewICalFormatter creates a Formatter that writes iCalendar components with the specified writer,
+// it can be used to register formats based on other ical.Writer implementations.
+func NewICalFormatter(config *Config, newWriter func(w io.Writer) ical.Writer) Formatter {
+	return &icalFormatter{config: config, enc: newWriter(config.Writer)}
+}
+
+func (f *icalFormatter) BeginCalendar(cal *Calendar) error {
+	f.cal = cal
+	return nil
+}
+
+func (f *icalFormatter) WriteEvent(ev *Event) error {
+	f.events = append(f.events, ev)
+	return nil
+}
+
+func (f *icalFormatter) EndCalendar() error {
+	if f.cal == nil {
+		return errors.New("calendar was not started")
+	}
+	if err := writeHeader(f.enc, f.config, f.cal); err != nil {
+		return errors.Wrapf(err, "unable to write header")
+	}
+
+	for _, usage := range collectTimeZones(f.events) {
+		if err := writeTimeZone(f.enc, usage); err != nil {
+			return errors.Wrapf(err, "unable to write timezone `%s'", usage.location)
+		}
+	}
+
+	for _, ev := range f.events {
+		if err := writeEvent(f.enc, f.config, ev); err != nil {
+			return errors.Wrap(err, "unable to write event")
+		}
+	}
+
+	if err := writeTrailer(f.enc); err != nil {
+		return errors.Wrapf(err, "unable to write trailer")
+	}
+	return nil
+}
+
+func writeHeader(enc ical.Writer, config *Config, cal *Calendar) error {
+	enc.Begin("VCALENDAR")
+	for _, p := range []ical.Property{
+		{Name: "VERSION", Value: "2.0"},
+		{Name: "PRODID", Value: "-//gcal-to-ics//gcal-to-ics-" + config.Version + "//EN"},
+		{Name: "CALSCALE", Value: "GREGORIAN"},
+		{Name: "METHOD", Value: "PUBLISH"},
+		{Name: "X-WR-TIMEZONE", Value: cal.TimeZone},
+		{Name: "X-WR-CALNAME", Value: cal.Summary},
+	} {
+		p := p
+		enc.WriteProperty(&p)
+	}
+	return enc.Err()
+}
+
+func writeTrailer(enc ical.Writer) error {
+	enc.End("VCALENDAR")
+	return enc.Err()
+}
+
+func writeEvent(enc ical.Writer, config *Config, ev *Event) error {
+	enc.Begin("VEVENT")
+
+	if ev.UID != "" {
+		enc.WriteProperty(&ical.Property{Name: "UID", Value: ev.UID})
+	}
+
+	writeEventTime(enc, ev)
+
+	if config.KeepRecurrence {
+		writeEventRecurrence(enc, ev)
+	}
+
+	enc.WriteProperty(&ical.Property{Name: "SUMMARY", Value: ev.Summary})
+	if ev.Description != "" {
+		enc.WriteProperty(&ical.Property{Name: "DESCRIPTION", Value: ev.Description})
+	}
+
+	switch ev.Transparency {
+	case TransparencyOpaque, TransparencyTransparent:
+		enc.WriteProperty(&ical.Property{Name: "TRANSP", Value: ev.Transparency})
+	}
+
+	if ev.Location != "" {
+		enc.WriteProperty(&ical.Property{Name: "LOCATION", Value: ev.Location})
+	}
+
+	switch ev.Visibility {
+	case VisibilityPublic, VisibilityPrivate:
+		enc.WriteProperty(&ical.Property{Name: "CLASS", Value: ev.Visibility})
+	}
+
+	if ev.ConferenceURI != "" {
+		enc.WriteProperty(&ical.Property{Name: "X-GOOGLE-CONFERENCE", Value: ev.ConferenceURI})
+	}
+
+	if ev.Organizer != nil {
+		if p := calAddressProperty("ORGANIZER", ev.Organizer.Email, ev.Organizer.DisplayName); p != nil {
+			enc.WriteProperty(p)
+		}
+	}
+
+	for _, attendee := range ev.Attendees {
+		if attendee.Email == "" {
+			continue
+		}
+
+		role := "REQ-PARTICIPANT"
+		if attendee.Optional {
+			role = "OPT-PARTICIPANT"
+		}
+		params := []ical.Param{{Name: "ROLE", Values: []string{role}}}
+		if attendee.ResponseStatus != "" {
+			params = append(params, ical.Param{Name: "PARTSTAT", Values: []string{attendee.ResponseStatus}})
+		}
+
+		displayName := attendee.DisplayName
+		if displayName == "" {
+			displayName = attendee.Email
+		}
+		params = append(params, ical.Param{Name: "CN", Values: []string{displayName}})
+
+		enc.WriteProperty(&ical.Property{
+			Name:   "ATTENDEE",
+			Params: params,
+			Value:  "mailto:" + attendee.Email,
+		})
+	}
+
+	switch ev.Status {
+	case StatusTentative, StatusCancelled, StatusConfirmed:
+		enc.WriteProperty(&ical.Property{Name: "STATUS", Value: ev.Status})
+	}
+
+	if !ev.Created.IsZero() {
+		enc.WriteProperty(&ical.Property{Name: "DTSTAMP", Value: ical.FormatDateTimeUTC(ev.Created)})
+		enc.WriteProperty(&ical.Property{Name: "CREATED", Value: ical.FormatDateTimeUTC(ev.Created)})
+	}
+	if !ev.Updated.IsZero() {
+		enc.WriteProperty(&ical.Property{Name: "LAST-MODIFIED", Value: ical.FormatDateTimeUTC(ev.Updated)})
+	}
+
+	writeAlarms(enc, config, ev)
+	enc.End("VEVENT")
+	return enc.Err()
+}
+
+// calAddressProperty returns a property for an address, if the email is not known the name is used
+// with an invalid address.
+func calAddressProperty(name, email, displayName string) *ical.Property {
+	if email == "" && displayName == "" {
+		return nil
+	}
+	if displayName == "" {
+		displayName = email
+	}
+	value := "invalid:nomail"
+	if email != "" {
+		value = "mailto:" + email
+	}
+	return &ical.Property{
+		Name:   name,
+		Params: []ical.Param{{Name: "CN", Values: []string{displayName}}},
+		Value:  value,
+	}
+}
+
+func writeEventTime(enc ical.Writer, ev *Event) {
+	if ev.Start.AllDay != ev.End.AllDay {
+		return
+	}
+	enc.WriteProperty(eventTimeProperty("DTSTART", ev.Start))
+	enc.WriteProperty(eventTimeProperty("DTEND", ev.End))
+}
+
+func writeEventRecurrence(enc ical.Writer, ev *Event) {
+	// modified instance of a recurring event
+	if ev.RecurringEventID != "" && ev.RecurrenceID != nil && !ev.RecurrenceID.IsZero() {
+		enc.WriteProperty(eventTimeProperty("RECURRENCE-ID", *ev.RecurrenceID))
+	}
+
+	for _, line := range ev.Recurrence {
+		p, err := ical.ParseProperty(line)
+		if err != nil {
+			continue
+		}
+		switch p.Name {
+		case "RRULE", "EXRULE", "RDATE", "EXDATE":
+			enc.WriteProperty(p)
+		}
+	}
+}
+
+// eventTimeProperty returns the property with the specified date or date time.
+// Date times are written in the local time of their timezone, or in UTC if the timezone is unknown.
+func eventTimeProperty(name string, t EventTime) *ical.Property {
+	if t.AllDay {
+		return &ical.Property{Name: name, Type: ical.TypeDate, Value: ical.FormatDate(t.Time)}
+	}
+	if loc := loadLocation(t.TimeZone); loc != nil {
+		return &ical.Property{
+			Name:   name,
+			Params: []ical.Param{{Name: "TZID", Values: []string{loc.String()}}},
+			Value:  ical.FormatDateTime(t.Time.In(loc)),
+		}
+	}
+	return &ical.Property{Name: name, Value: ical.FormatDateTimeUTC(t.Time)}
+}
@@ -0,0 +1,355 @@
+package gti
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Eun/gcal-to-ics/pkg/ical"
+	"github.com/pkg/errors"
+)
+
+// readICS reads a VCALENDAR and returns the events in the time range of the config.
+func readICS(r io.Reader, config *Config) (*Calendar, []Event, error) {
+	root, err := ical.Decode(r)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to decode calendar")
+	}
+	if root.Name != "VCALENDAR" {
+		return nil, nil, errors.Errorf("expected VCALENDAR, got %s", root.Name)
+	}
+
+	zones := readICSTimeZones(root)
+	cal := &Calendar{
+		Summary:     root.PropertyValue("X-WR-CALNAME"),
+		Description: root.PropertyValue("X-WR-CALDESC"),
+		TimeZone:    root.PropertyValue("X-WR-TIMEZONE"),
+	}
+	if name := zones.name(cal.TimeZone); name != "" {
+		cal.TimeZone = name
+	}
+
+	var all []Event
+	for _, c := range root.Components {
+		if c.Name != "VEVENT" {
+			continue
+		}
+		if ev, ok := convertICSEvent(c, zones, cal.TimeZone, config.AccountEmail); ok {
+			all = append(all, ev)
+		}
+	}
+	excludeCancelledInstances(all)
+
+	var events []Event
+	for i := range all {
+		ev := &all[i]
+		if ev.Status == StatusCancelled || !inRange(ev, config.StartFrom, config.EndOn) {
+			continue
+		}
+		if len(ev.Recurrence) > 0 && !config.KeepRecurrence {
+			config.Logger.Warn().
+				Str("uid", ev.UID).
+				Msg("recurring events of ics files are not expanded, only the first instance is exported without keep recurrence")
+		}
+		events = append(events, *ev)
+	}
+	return cal, events, nil
+}
+
+// excludeCancelledInstances adds the cancelled instances of recurring events as EXDATE to their series,
+// so they are not exported as a regular occurrence once the cancelled instance is dropped.
+func excludeCancelledInstances(events []Event) {
+	series := make(map[string]*Event)
+	for i := range events {
+		if isSeries(&events[i]) {
+			series[events[i].UID] = &events[i]
+		}
+	}
+	for i := range events {
+		ev := &events[i]
+		if ev.Status != StatusCancelled || ev.RecurrenceID == nil {
+			continue
+		}
+		if s, ok := series[ev.UID]; ok {
+			s.Recurrence = append(s.Recurrence, ical.FormatProperty(eventTimeProperty("EXDATE", *ev.RecurrenceID)))
+		}
+	}
+}
+
+// inRange reports whether the event happens between from and to.
+// Recurring events are included if they start before to, because their last occurrence is not known.
+func inRange(ev *Event, from, to time.Time) bool {
+	if !to.IsZero() && !ev.Start.Time.Before(to) {
+		return false
+	}
+	if len(ev.Recurrence) > 0 || from.IsZero() {
+		return true
+	}
+	return ev.End.Time.After(from)
+}
+
+func convertICSEvent(c *ical.Component, zones icsTimeZones, calendarTimeZone, accountEmail string) (Event, bool) {
+	ev := Event{
+		UID:         c.PropertyValue("UID"),
+		Summary:     c.PropertyValue("SUMMARY"),
+		Description: c.PropertyValue("DESCRIPTION"),
+		Location:    c.PropertyValue("LOCATION"),
+		HTMLLink:    c.PropertyValue("URL"),
+	}
+	if ev.UID == "" {
+		return ev, false
+	}
+	ev.ID = ev.UID
+
+	start := c.Property("DTSTART")
+	if start == nil {
+		return ev, false
+	}
+	var ok bool
+	if ev.Start, ok = icsEventTime(start, zones, calendarTimeZone); !ok {
+		return ev, false
+	}
+	if end := c.Property("DTEND"); end != nil {
+		if ev.End, ok = icsEventTime(end, zones, calendarTimeZone); !ok {
+			return ev, false
+		}
+	} else {
+		ev.End = ev.Start
+		if d, err := ical.ParseDuration(c.PropertyValue("DURATION")); err == nil {
+			ev.End.Time = ev.End.Time.Add(d)
+		} else if ev.Start.AllDay {
+			ev.End.Time = ev.End.Time.AddDate(0, 0, 1)
+		}
+	}
+
+	if p := c.Property("RECURRENCE-ID"); p != nil {
+		if t, ok := icsEventTime(p, zones, calendarTimeZone); ok {
+			ev.RecurrenceID = &t
+			ev.RecurringEventID = ev.UID
+			ev.ID = ev.UID + "_" + p.Value
+		}
+	}
+	for _, p := range c.Properties {
+		switch p.Name {
+		case "RRULE", "EXRULE", "RDATE", "EXDATE":
+			// the VTIMEZONE components of the feed are not exported, so the TZID has to be an IANA timezone
+			for i := range p.Params {
+				if strings.EqualFold(p.Params[i].Name, "TZID") && len(p.Params[i].Values) > 0 {
+					if name := zones.name(p.Params[i].Values[0]); name != "" {
+						p.Params[i].Values = []string{name}
+					}
+				}
+			}
+			ev.Recurrence = append(ev.Recurrence, ical.FormatProperty(p))
+		}
+	}
+
+	switch status := strings.ToUpper(c.PropertyValue("STATUS")); status {
+	case StatusConfirmed, StatusTentative, StatusCancelled:
+		ev.Status = status
+	}
+	switch transparency := strings.ToUpper(c.PropertyValue("TRANSP")); transparency {
+	case TransparencyOpaque, TransparencyTransparent:
+		ev.Transparency = transparency
+	}
+	switch visibility := strings.ToUpper(c.PropertyValue("CLASS")); visibility {
+	case VisibilityPublic, VisibilityPrivate:
+		ev.Visibility = visibility
+	}
+
+	if p := c.Property("ORGANIZER"); p != nil {
+		ev.Organizer = &Person{Email: mailAddress(p.Value), DisplayName: p.Param("CN")}
+	}
+	for _, p := range c.Properties {
+		if p.Name != "ATTENDEE" {
+			continue
+		}
+		email := mailAddress(p.Value)
+		if email == "" {
+			continue
+		}
+		attendee := Attendee{
+			Person:   Person{Email: email, DisplayName: p.Param("CN")},
+			Optional: strings.EqualFold(p.Param("ROLE"), "OPT-PARTICIPANT"),
+			Self:     accountEmail != "" && strings.EqualFold(email, accountEmail),
+		}
+		switch partstat := strings.ToUpper(p.Param("PARTSTAT")); partstat {
+		case ResponseNeedsAction, ResponseAccepted, ResponseDeclined, ResponseTentative:
+			attendee.ResponseStatus = partstat
+		}
+		ev.Attendees = append(ev.Attendees, attendee)
+	}
+
+	ev.ConferenceURI = c.PropertyValue("X-GOOGLE-CONFERENCE")
+
+	for _, alarm := range c.Components {
+		if alarm.Name != "VALARM" {
+			continue
+		}
+		trigger := alarm.Property("TRIGGER")
+		// only alarms relative to the start are supported
+		if trigger == nil || trigger.ValueType() != ical.TypeDuration || strings.EqualFold(trigger.Param("RELATED"), "END") {
+			continue
+		}
+		d, err := ical.ParseDuration(trigger.Value)
+		if err != nil || d > 0 {
+			continue
+		}
+		method := ReminderDisplay
+		if strings.EqualFold(alarm.PropertyValue("ACTION"), "EMAIL") {
+			method = ReminderEmail
+		}
+		ev.Reminders = append(ev.Reminders, Reminder{Method: method, Before: -d})
+	}
+
+	ev.Created = icsUTCTime(c.PropertyValue("CREATED"))
+	ev.Updated = icsUTCTime(c.PropertyValue("LAST-MODIFIED"))
+	if ev.Updated.IsZero() {
+		ev.Updated = icsUTCTime(c.PropertyValue("DTSTAMP"))
+	}
+	return ev, true
+}
+
+// icsEventTime converts a DATE or DATE-TIME property.
+// Times without a known TZID are floating and interpreted in the timezone of the calendar.
+func icsEventTime(p *ical.Property, zones icsTimeZones, calendarTimeZone string) (EventTime, bool) {
+	if p.ValueType() == ical.TypeDate {
+		t, err := ical.ParseDate(p.Value)
+		if err != nil {
+			return EventTime{}, false
+		}
+		return EventTime{Time: t, AllDay: true, TimeZone: calendarTimeZone}, true
+	}
+
+	timeZone := zones.name(p.Param("TZID"))
+	loc := loadLocation(timeZone)
+	if loc == nil {
+		timeZone = calendarTimeZone
+		loc = loadLocation(timeZone)
+	}
+	t, err := ical.ParseDateTime(p.Value, loc)
+	if err != nil {
+		return EventTime{}, false
+	}
+	if loc == nil {
+		timeZone = ""
+	}
+	return EventTime{Time: t, TimeZone: timeZone}, true
+}
+
+// icsTimeZones maps the TZIDs of the VTIMEZONE components of a feed that are no IANA timezones,
+// e.g. W. Europe Standard Time of Outlook, to an IANA timezone.
+type icsTimeZones map[string]string
+
+// readICSTimeZones resolves the TZIDs of the VTIMEZONE components by their Windows name, their X-LIC-LOCATION or
+// their offsets.
+func readICSTimeZones(root *ical.Component) icsTimeZones {
+	zones := make(icsTimeZones)
+	for _, c := range root.Components {
+		if c.Name != "VTIMEZONE" {
+			continue
+		}
+		tzid := c.PropertyValue("TZID")
+		if tzid == "" || loadLocation(tzid) != nil {
+			continue
+		}
+		switch location := c.PropertyValue("X-LIC-LOCATION"); {
+		case windowsTimeZones[tzid] != "":
+			zones[tzid] = windowsTimeZones[tzid]
+		case loadLocation(location) != nil:
+			zones[tzid] = location
+		default:
+			if name := matchTimeZoneOffsets(c); name != "" {
+				zones[tzid] = name
+			}
+		}
+	}
+	return zones
+}
+
+// name returns the IANA timezone of the TZID, it is empty if the TZID is unknown.
+func (z icsTimeZones) name(tzid string) string {
+	if loadLocation(tzid) != nil {
+		return tzid
+	}
+	if name, ok := z[tzid]; ok {
+		return name
+	}
+	// Windows names are also used without a VTIMEZONE
+	return windowsTimeZones[tzid]
+}
+
+// matchTimeZoneOffsets returns the first of the windowsTimeZones that currently has the same standard and daylight
+// offsets as the VTIMEZONE.
+func matchTimeZoneOffsets(c *ical.Component) string {
+	var standard, daylight int
+	var standardStart, daylightStart time.Time
+	daylightRule := false
+	for _, observance := range c.Components {
+		offset, err := ical.ParseUTCOffset(observance.PropertyValue("TZOFFSETTO"))
+		if err != nil {
+			continue
+		}
+		start, err := ical.ParseDateTime(observance.PropertyValue("DTSTART"), time.UTC)
+		if err != nil {
+			continue
+		}
+		switch {
+		case observance.Name == "STANDARD" && !start.Before(standardStart):
+			standard, standardStart = offset, start
+		case observance.Name == "DAYLIGHT" && !start.Before(daylightStart):
+			daylight, daylightStart = offset, start
+			daylightRule = observance.Property("RRULE") != nil
+		}
+	}
+	if standardStart.IsZero() {
+		return ""
+	}
+	// the daylight saving time is not used anymore if it does not repeat and the standard time started after it
+	if daylightStart.IsZero() || (!daylightRule && daylightStart.Before(standardStart)) {
+		daylight = standard
+	}
+
+	// the observances are valid from their start on, which is often 1601 for Outlook
+	year := time.Now().Year()
+	if y := standardStart.Year(); y > year {
+		year = y
+	}
+	names := make([]string, 0, len(windowsTimeZones))
+	for _, name := range windowsTimeZones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		loc := loadLocation(name)
+		if loc == nil {
+			continue
+		}
+		_, january := time.Date(year, time.January, 1, 0, 0, 0, 0, loc).Zone()
+		_, july := time.Date(year, time.July, 1, 0, 0, 0, 0, loc).Zone()
+		if (january == standard && july == daylight) || (january == daylight && july == standard) {
+			return name
+		}
+	}
+	return ""
+}
+
+func icsUTCTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	t, err := ical.ParseDateTime(s, time.UTC)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// mailAddress returns the email address of a mailto uri.
+func mailAddress(uri string) string {
+	if len(uri) > len("mailto:") && strings.EqualFold(uri[:len("mailto:")], "mailto:") {
+		return uri[len("mailto:"):]
+	}
+	return ""
+}
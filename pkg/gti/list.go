@@ -0,0 +1,47 @@
+package gti
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// CalendarListEntry describes one of the calendars of an account.
+type CalendarListEntry struct {
+	ID         string `json:"id"`
+	Summary    string `json:"summary"`
+	AccessRole string `json:"access_role,omitempty"`
+	TimeZone   string `json:"time_zone,omitempty"`
+	Hidden     bool   `json:"hidden,omitempty"`
+	Primary    bool   `json:"primary,omitempty"`
+}
+
+// ListCalendars returns the calendars of the account of the Client of the config,
+// including the hidden ones.
+func ListCalendars(ctx context.Context, config *Config) ([]CalendarListEntry, error) {
+	if config == nil {
+		return nil, errors.New("config cannot be nil")
+	}
+	service, err := calendar.NewService(ctx, option.WithHTTPClient(config.Client))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create calendar service")
+	}
+	entries, err := listCalendars(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]CalendarListEntry, len(entries))
+	for i, entry := range entries {
+		result[i] = CalendarListEntry{
+			ID:         entry.Id,
+			Summary:    entry.Summary,
+			AccessRole: entry.AccessRole,
+			TimeZone:   entry.TimeZone,
+			Hidden:     entry.Hidden,
+			Primary:    entry.Primary,
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,19 @@
+package gti
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListCalendars(t *testing.T) {
+	server := newFakeServer(t, "calendar.json")
+
+	entries, err := ListCalendars(context.Background(), &Config{Client: server.Client()})
+	require.NoError(t, err)
+	require.Equal(t, []CalendarListEntry{
+		{ID: "private@example.com", Summary: "Private", TimeZone: "Europe/Berlin", Primary: true},
+		{ID: "work@example.com", Summary: "Work", TimeZone: "Europe/Berlin", Hidden: true},
+	}, entries)
+}
@@ -0,0 +1,149 @@
+package gti
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MergedCalendar is one of the calendars of a MergeSource.
+type MergedCalendar struct {
+	// Config selects the calendar (Source, Client, AccountEmail, CalendarName and StoreDir),
+	// the events that are included (Filter), the fields that are hidden, overwritten, rewritten with
+	// templates or redacted for its events and whether only its busy time is merged (Privacy, BusyLabel and BusySlot).
+	// The time range and KeepRecurrence of the config of the MergeSource are used instead of its own,
+	// the StoreDir of the config of the MergeSource is used if it has none.
+	Config *Config
+	// SummaryPrefix is prepended to the summary of every event of the calendar.
+	SummaryPrefix string
+}
+
+// MergeSource combines the events of several calendars into one calendar.
+// Events that share a UID (and RECURRENCE-ID) are only included once, the first calendar wins.
+type MergeSource struct {
+	// Calendar describes the merged calendar, empty fields are taken from the merged calendars.
+	Calendar  Calendar
+	Calendars []MergedCalendar
+}
+
+// Fetch implements Source.
+func (s MergeSource) Fetch(ctx context.Context, config *Config) (*Calendar, []Event, error) {
+	if len(s.Calendars) == 0 {
+		return nil, nil, errors.New("no calendars to merge")
+	}
+
+	cal := s.Calendar
+	var ids, summaries []string
+	var events []Event
+	seen := make(map[string]struct{})
+	for i, mc := range s.Calendars {
+		if mc.Config == nil {
+			return nil, nil, errors.Errorf("calendar %d has no config", i)
+		}
+		cfg := *mc.Config
+		cfg.Logger = config.Logger
+		cfg.StartFrom = config.StartFrom
+		cfg.EndOn = config.EndOn
+		cfg.KeepRecurrence = config.KeepRecurrence
+		cfg.SnapshotDir = ""
+		cfg.Offline = false
+		if cfg.StoreDir == "" {
+			cfg.StoreDir = config.StoreDir
+		}
+
+		filter, err := newEventFilter(&cfg.Filter)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid filter of calendar %d", i)
+		}
+		templates, err := newFieldTemplates(&cfg.TemplateFields)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid template fields of calendar %d", i)
+		}
+		redactor, err := newRedactor(&cfg.Redaction)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid redaction of calendar %d", i)
+		}
+		switch cfg.Privacy {
+		case PrivacyFull, PrivacyBusy:
+		default:
+			return nil, nil, errors.Errorf("privacy `%s' of calendar %d is not supported", cfg.Privacy, i)
+		}
+		c, evs, err := Fetch(ctx, &cfg)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to fetch calendar %d", i)
+		}
+		ids = append(ids, c.ID)
+		summaries = append(summaries, c.Summary)
+		if cal.TimeZone == "" {
+			cal.TimeZone = c.TimeZone
+		}
+
+		exdates := excludedInstances(evs, func(ev *Event) bool {
+			return filter.match(ev) && (cfg.Privacy != PrivacyBusy || busy(ev))
+		})
+		for j := range evs {
+			ev := &evs[j]
+			if !filter.match(ev) {
+				continue
+			}
+			if cfg.Privacy == PrivacyBusy && !busy(ev) {
+				continue
+			}
+			if isSeries(ev) {
+				ev.Recurrence = append(ev.Recurrence, exdates[ev.UID]...)
+			}
+			if key := mergeKey(ev); key != "" {
+				if _, ok := seen[key]; ok {
+					continue
+				}
+				seen[key] = struct{}{}
+			}
+			fetched := ev.clone()
+			applyEventFields(&cfg, ev)
+			if err := templates.apply(c, &cfg.HideFields, fetched, ev); err != nil {
+				return nil, nil, errors.Wrapf(err, "unable to apply the templates of calendar %d", i)
+			}
+			redactor.apply(ev)
+			if cfg.Privacy == PrivacyBusy {
+				applyBusyPrivacy(&cfg, ev)
+			} else if !busy(fetched) && busy(ev) {
+				// the hidden attendees were needed to know that the owner declined the event,
+				// so it is kept from blocking time in the busy privacy mode and free/busy formats,
+				// without showing a transparency that is hidden
+				if cfg.HideFields.Transparency {
+					ev.Free = true
+				} else {
+					ev.Transparency = TransparencyTransparent
+				}
+			}
+			if ev.Summary != "" {
+				ev.Summary = mc.SummaryPrefix + ev.Summary
+			}
+			events = append(events, *ev)
+		}
+		config.Logger.Debug().
+			Str("calendar_id", c.ID).
+			Msgf("merging %d events", len(evs))
+	}
+
+	if cal.ID == "" {
+		cal.ID = strings.Join(ids, ",")
+	}
+	if cal.Summary == "" {
+		cal.Summary = strings.Join(summaries, ", ")
+	}
+	return &cal, events, nil
+}
+
+// mergeKey identifies an event across calendars, it is empty if the event has no UID.
+func mergeKey(ev *Event) string {
+	if ev.UID == "" {
+		return ""
+	}
+	if ev.RecurrenceID != nil {
+		return ev.UID + "/" + ev.RecurrenceID.Time.UTC().Format(time.RFC3339)
+	}
+	return ev.UID
+}
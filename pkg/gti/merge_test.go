@@ -0,0 +1,192 @@
+package gti
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeSource(t *testing.T) {
+	server := newFakeServer(t, "calendar.json")
+	path := filepath.Join(t.TempDir(), "home.ics")
+	require.NoError(t, os.WriteFile(path, []byte("BEGIN:VCALENDAR\r\n"+
+		"VERSION:2.0\r\n"+
+		"X-WR-CALNAME:Home\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"UID:doctor@google.com\r\n"+
+		"DTSTART:20240507T140000Z\r\n"+
+		"DTEND:20240507T150000Z\r\n"+
+		"SUMMARY:Doctor\r\n"+
+		"END:VEVENT\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"UID:gym@example.com\r\n"+
+		"DTSTART:20240508T170000Z\r\n"+
+		"DTEND:20240508T180000Z\r\n"+
+		"SUMMARY:Gym\r\n"+
+		"LOCATION:Gym\r\n"+
+		"END:VEVENT\r\n"+
+		"END:VCALENDAR\r\n"), 0o600))
+
+	config := &Config{
+		Logger:    nopLogger(),
+		StartFrom: time.Date(2024, time.May, 6, 0, 0, 0, 0, time.UTC),
+		EndOn:     time.Date(2024, time.May, 13, 0, 0, 0, 0, time.UTC),
+		Source: MergeSource{
+			Calendar: Calendar{Summary: "Team"},
+			Calendars: []MergedCalendar{
+				{
+					Config: &Config{
+						CalendarName: "Work",
+						Client:       server.Client(),
+						HideFields:   HideFields{Location: true},
+					},
+					SummaryPrefix: "[Work] ",
+				},
+				{
+					Config:        &Config{Source: ICSSource{Path: path}},
+					SummaryPrefix: "[Home] ",
+				},
+			},
+		},
+	}
+
+	cal, events, err := Fetch(context.Background(), config)
+	require.NoError(t, err)
+	require.Equal(t, "Team", cal.Summary)
+	require.Equal(t, "work@example.com,"+path, cal.ID)
+	require.Equal(t, "Europe/Berlin", cal.TimeZone)
+
+	var summaries, locations []string
+	for i := range events {
+		summaries = append(summaries, events[i].Summary)
+		locations = append(locations, events[i].Location)
+	}
+	require.Equal(t, []string{"[Work] Weekly sync", "[Work] Holiday", "[Work] Doctor", "", "[Home] Gym"}, summaries)
+	require.Equal(t, []string{"", "", "", "", "Gym"}, locations)
+
+	_, _, err = MergeSource{}.Fetch(context.Background(), config)
+	require.Error(t, err)
+}
+
+func TestMergeSourcePrivacy(t *testing.T) {
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work.ics")
+	require.NoError(t, os.WriteFile(work, []byte("BEGIN:VCALENDAR\r\n"+
+		"VERSION:2.0\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"UID:review@example.com\r\n"+
+		"DTSTART:20240507T090000Z\r\n"+
+		"DTEND:20240507T100000Z\r\n"+
+		"SUMMARY:Review\r\n"+
+		"DESCRIPTION:Dial in with PIN: 1234\r\n"+
+		"END:VEVENT\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"UID:offsite@example.com\r\n"+
+		"DTSTART:20240508T090000Z\r\n"+
+		"DTEND:20240508T170000Z\r\n"+
+		"SUMMARY:Offsite\r\n"+
+		"ATTENDEE;PARTSTAT=DECLINED:mailto:me@example.com\r\n"+
+		"END:VEVENT\r\n"+
+		"END:VCALENDAR\r\n"), 0o600))
+	home := filepath.Join(dir, "home.ics")
+	require.NoError(t, os.WriteFile(home, []byte("BEGIN:VCALENDAR\r\n"+
+		"VERSION:2.0\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"UID:doctor@example.com\r\n"+
+		"DTSTART:20240509T140000Z\r\n"+
+		"DTEND:20240509T150000Z\r\n"+
+		"SUMMARY:Doctor\r\n"+
+		"LOCATION:Clinic\r\n"+
+		"END:VEVENT\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"UID:party@example.com\r\n"+
+		"DTSTART:20240510T180000Z\r\n"+
+		"DTEND:20240510T230000Z\r\n"+
+		"SUMMARY:Party\r\n"+
+		"TRANSP:TRANSPARENT\r\n"+
+		"END:VEVENT\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"UID:yoga@example.com\r\n"+
+		"DTSTART:20240506T070000Z\r\n"+
+		"DTEND:20240506T080000Z\r\n"+
+		"RRULE:FREQ=DAILY;COUNT=3\r\n"+
+		"SUMMARY:Yoga\r\n"+
+		"END:VEVENT\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"UID:yoga@example.com\r\n"+
+		"RECURRENCE-ID:20240507T070000Z\r\n"+
+		"DTSTART:20240507T070000Z\r\n"+
+		"DTEND:20240507T080000Z\r\n"+
+		"SUMMARY:Yoga\r\n"+
+		"TRANSP:TRANSPARENT\r\n"+
+		"END:VEVENT\r\n"+
+		"END:VCALENDAR\r\n"), 0o600))
+
+	config := &Config{
+		Logger:         nopLogger(),
+		StartFrom:      time.Date(2024, time.May, 6, 0, 0, 0, 0, time.UTC),
+		EndOn:          time.Date(2024, time.May, 13, 0, 0, 0, 0, time.UTC),
+		KeepRecurrence: true,
+		Source: MergeSource{
+			Calendars: []MergedCalendar{
+				{
+					Config: &Config{
+						AccountEmail: "me@example.com",
+						Source:       ICSSource{Path: work},
+						HideFields:   HideFields{Attendees: true},
+						Redaction:    Redaction{Builtin: []string{"passcodes"}},
+					},
+				},
+				{
+					Config: &Config{
+						Source:    ICSSource{Path: home},
+						Privacy:   PrivacyBusy,
+						BusyLabel: "Away",
+					},
+					SummaryPrefix: "[Home] ",
+				},
+			},
+		},
+	}
+
+	_, events, err := Fetch(context.Background(), config)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	require.Equal(t, "Dial in with PIN: [redacted]", events[0].Description)
+	// the declined event does not block time, even though its attendees are hidden
+	require.Equal(t, "Offsite", events[1].Summary)
+	require.Empty(t, events[1].Attendees)
+	require.False(t, busy(&events[1]))
+	require.Equal(t, TransparencyTransparent, events[1].Transparency)
+	// only the busy time of the home calendar is merged
+	require.Equal(t, "[Home] Away", events[2].Summary)
+	require.Empty(t, events[2].Location)
+	// the transparent instance is not busy, so the series must not create it again
+	require.Equal(t, []string{"RRULE:FREQ=DAILY;COUNT=3", "EXDATE:20240507T070000Z"}, events[3].Recurrence)
+
+	// a hidden transparency is not shown for the declined event, it is still free
+	config.Source.(MergeSource).Calendars[0].Config.HideFields.Transparency = true
+	_, events, err = Fetch(context.Background(), config)
+	require.NoError(t, err)
+	require.Equal(t, "Offsite", events[1].Summary)
+	require.Empty(t, events[1].Transparency)
+	require.False(t, busy(&events[1]))
+
+	var buf strings.Builder
+	cfg := *config
+	cfg.Format = "ics"
+	cfg.Privacy = PrivacyBusy
+	cfg.Writer = &buf
+	require.NoError(t, Write(&cfg, &Calendar{}, events))
+	// the declined event is dropped from the busy time
+	require.Equal(t, 3, strings.Count(buf.String(), "BEGIN:VEVENT"))
+
+	config.Source.(MergeSource).Calendars[1].Config.Privacy = "unknown"
+	_, _, err = Fetch(context.Background(), config)
+	require.Error(t, err)
+}
@@ -0,0 +1,144 @@
+package gti
+
+import (
+	"time"
+)
+
+// Calendar holds the details of a calendar.
+type Calendar struct {
+	ID          string `json:"id,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+	Description string `json:"description,omitempty"`
+	// TimeZone is the IANA timezone of the calendar, e.g. Europe/Berlin.
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+// EventTime is the start or end of an event.
+type EventTime struct {
+	// Time is the point in time, for all day events only the date is relevant.
+	Time time.Time `json:"time"`
+	// AllDay is true if the event lasts the whole day.
+	AllDay bool `json:"all_day,omitempty"`
+	// TimeZone is the IANA timezone the time is written in, if empty the time is written in UTC.
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+// IsZero reports whether the time is not set.
+func (t EventTime) IsZero() bool {
+	return t.Time.IsZero()
+}
+
+// Person is an organizer or attendee of an event.
+type Person struct {
+	Email       string `json:"email,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// Response statuses of an attendee.
+const (
+	ResponseNeedsAction = "NEEDS-ACTION"
+	ResponseAccepted    = "ACCEPTED"
+	ResponseDeclined    = "DECLINED"
+	ResponseTentative   = "TENTATIVE"
+)
+
+// Attendee is an attendee of an event.
+type Attendee struct {
+	Person
+	Optional bool `json:"optional,omitempty"`
+	// ResponseStatus is one of the Response constants.
+	ResponseStatus string `json:"response_status,omitempty"`
+	// Self is true if the attendee is the owner of the calendar.
+	Self bool `json:"self,omitempty"`
+}
+
+// Reminder methods.
+const (
+	ReminderDisplay = "DISPLAY"
+	ReminderEmail   = "EMAIL"
+)
+
+// Reminder is a notification before the event starts.
+type Reminder struct {
+	// Method is one of the Reminder constants.
+	Method string `json:"method,omitempty"`
+	// Before is the duration before the start of the event.
+	Before time.Duration `json:"before,omitempty"`
+}
+
+// Statuses of an event.
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusTentative = "TENTATIVE"
+	StatusCancelled = "CANCELLED" //nolint: misspell // not a misspell
+)
+
+// Transparencies of an event.
+const (
+	TransparencyOpaque      = "OPAQUE"
+	TransparencyTransparent = "TRANSPARENT"
+)
+
+// Visibilities of an event.
+const (
+	VisibilityPublic  = "PUBLIC"
+	VisibilityPrivate = "PRIVATE"
+)
+
+// Event is a single event, a recurring event or a modified instance of a recurring event.
+type Event struct {
+	ID          string `json:"id,omitempty"`
+	UID         string `json:"uid,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+	Description string `json:"description,omitempty"`
+	Location    string `json:"location,omitempty"`
+
+	Start EventTime `json:"start"`
+	End   EventTime `json:"end"`
+
+	// Recurrence holds the RRULE, EXRULE, RDATE and EXDATE lines of a recurring event.
+	Recurrence []string `json:"recurrence,omitempty"`
+	// RecurringEventID is the id of the recurring event this event is an instance of.
+	RecurringEventID string `json:"recurring_event_id,omitempty"`
+	// RecurrenceID is the original start of an instance of a recurring event.
+	RecurrenceID *EventTime `json:"recurrence_id,omitempty"`
+
+	// Status is one of the Status constants, or empty if unknown.
+	Status string `json:"status,omitempty"`
+	// Transparency is one of the Transparency constants, or empty if unknown.
+	Transparency string `json:"transparency,omitempty"`
+	// Free marks an event that does not block time, although its hidden transparency or attendees do not tell it
+	// anymore, e.g. a declined event of a merged calendar.
+	Free bool `json:"free,omitempty"`
+	// Visibility is one of the Visibility constants, or empty if unknown.
+	Visibility string `json:"visibility,omitempty"`
+
+	Organizer     *Person    `json:"organizer,omitempty"`
+	Attendees     []Attendee `json:"attendees,omitempty"`
+	ConferenceURI string     `json:"conference_uri,omitempty"`
+	Reminders     []Reminder `json:"reminders,omitempty"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+
+	HTMLLink  string `json:"html_link,omitempty"`
+	ColorID   string `json:"color_id,omitempty"`
+	EventType string `json:"event_type,omitempty"`
+}
+
+// clone returns a copy of the event that does not share any slices or pointers with the original.
+func (ev *Event) clone() *Event {
+	c := *ev
+	c.Recurrence = append([]string(nil), ev.Recurrence...)
+	c.Attendees = append([]Attendee(nil), ev.Attendees...)
+	c.Reminders = append([]Reminder(nil), ev.Reminders...)
+	if ev.RecurrenceID != nil {
+		t := *ev.RecurrenceID
+		c.RecurrenceID = &t
+	}
+	if ev.Organizer != nil {
+		p := *ev.Organizer
+		c.Organizer = &p
+	}
+	return &c
+}
@@ -0,0 +1,69 @@
+package gti
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Privacy modes.
+const (
+	// PrivacyFull exports the events with all fields that are not hidden.
+	PrivacyFull = ""
+	// PrivacyBusy only exports when the calendar is busy.
+	PrivacyBusy = "busy"
+)
+
+// defaultBusyLabel is the summary of the events in the busy privacy mode.
+const defaultBusyLabel = "Busy"
+
+// busy reports whether the event blocks time, transparent events and events the owner declined do not.
+func busy(ev *Event) bool {
+	return !ev.Free && ev.Transparency != TransparencyTransparent && !declined(ev)
+}
+
+// applyBusyPrivacy replaces the summary with the busy label and removes every property that could identify the
+// event, only the time, the recurrence and the status are kept.
+// The UID is replaced by a hash, so clients can still update the event.
+func applyBusyPrivacy(config *Config, ev *Event) {
+	label := config.BusyLabel
+	if label == "" {
+		label = defaultBusyLabel
+	}
+	if ev.UID != "" {
+		sum := sha256.Sum256([]byte(ev.UID))
+		ev.UID = hex.EncodeToString(sum[:16]) + "@gcal-to-ics"
+	}
+	ev.Summary = label
+	ev.Description = ""
+	ev.Location = ""
+	ev.ConferenceURI = ""
+	ev.Organizer = nil
+	ev.Attendees = nil
+	ev.Reminders = nil
+	ev.Visibility = ""
+	ev.Transparency = TransparencyOpaque
+	ev.HTMLLink = ""
+	ev.ColorID = ""
+	ev.EventType = ""
+
+	// series keep their times, otherwise the instances would not match their EXDATE and RECURRENCE-ID anymore
+	if config.BusySlot > 0 && !ev.Start.AllDay && len(ev.Recurrence) == 0 && ev.RecurrenceID == nil {
+		ev.Start.Time = floorTime(ev.Start.Time, config.BusySlot)
+		ev.End.Time = ceilTime(ev.End.Time, config.BusySlot)
+	}
+}
+
+// floorTime rounds t down to a multiple of slot.
+func floorTime(t time.Time, slot time.Duration) time.Time {
+	return t.Truncate(slot)
+}
+
+// ceilTime rounds t up to a multiple of slot.
+func ceilTime(t time.Time, slot time.Duration) time.Time {
+	f := t.Truncate(slot)
+	if f.Equal(t) {
+		return t
+	}
+	return f.Add(slot)
+}
@@ -0,0 +1,59 @@
+package gti
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBusyPrivacy(t *testing.T) {
+	var buf bytes.Buffer
+	config := &Config{
+		Format:   "jcal",
+		Logger:   nopLogger(),
+		Writer:   &buf,
+		Version:  "test",
+		Privacy:  PrivacyBusy,
+		BusySlot: 15 * time.Minute,
+	}
+
+	at := func(hour, min int) EventTime {
+		return EventTime{Time: time.Date(2024, time.May, 1, hour, min, 0, 0, time.UTC)}
+	}
+	events := []Event{
+		{
+			UID:           "1@google.com",
+			Summary:       "Interview with Alice",
+			Description:   "Salary negotiation",
+			Location:      "Room 1",
+			Start:         at(10, 5),
+			End:           at(10, 50),
+			Organizer:     &Person{Email: "boss@example.com"},
+			Attendees:     []Attendee{{Person: Person{Email: "alice@example.com"}}},
+			ConferenceURI: "https://meet.google.com/abc",
+			Reminders:     []Reminder{{Method: ReminderDisplay, Before: time.Minute}},
+		},
+		{UID: "2@google.com", Summary: "Lunch", Start: at(12, 0), End: at(13, 0), Transparency: TransparencyTransparent},
+		{
+			UID:       "3@google.com",
+			Summary:   "Declined",
+			Start:     at(14, 0),
+			End:       at(15, 0),
+			Attendees: []Attendee{{Person: Person{Email: "me@example.com"}, Self: true, ResponseStatus: ResponseDeclined}},
+		},
+	}
+	require.NoError(t, Write(config, &Calendar{ID: "work", Summary: "Work", Description: "Secret projects"}, events))
+
+	out := buf.String()
+	require.Contains(t, out, `["summary",{},"text","Busy"]`)
+	require.Contains(t, out, `["dtstart",{},"date-time","2024-05-01T10:00:00Z"]`)
+	require.Contains(t, out, `["dtend",{},"date-time","2024-05-01T11:00:00Z"]`)
+	for _, s := range []string{"Alice", "Salary", "Room 1", "boss@", "meet.google", "1@google.com", "Lunch", "Declined", "Secret", "valarm"} {
+		require.NotContains(t, out, s)
+	}
+
+	config.Privacy = "invisible"
+	require.Error(t, Write(config, &Calendar{}, events))
+}
@@ -0,0 +1,129 @@
+package gti
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// defaultRedaction replaces the matches of rules without a replacement.
+const defaultRedaction = "[redacted]"
+
+// Redaction removes sensitive data from the summary, description and location of the events.
+// The Builtin rules are applied first in the order of builtinRedactions, the custom Rules afterwards.
+type Redaction struct {
+	// Builtin are the names of built-in rules: emails, phones, meet, zoom and passcodes.
+	Builtin []string        `yaml:"builtin" json:"builtin,omitempty"`
+	Rules   []RedactionRule `yaml:"rules" json:"rules,omitempty"`
+}
+
+// RedactionRule replaces the matches of a regular expression.
+type RedactionRule struct {
+	Pattern string `yaml:"pattern" json:"pattern"`
+	// Replacement can use $1 for groups, [redacted] is used if it is empty.
+	Replacement string `yaml:"replacement" json:"replacement,omitempty"`
+}
+
+type builtinRedaction struct {
+	name string
+	re   *regexp.Regexp
+	repl string
+}
+
+// builtinRedactions are the built-in rules, links come first so the phone numbers and passcodes in them are
+// replaced as a whole. A name can have several rules.
+var builtinRedactions = []builtinRedaction{
+	{
+		name: "meet",
+		re:   regexp.MustCompile(`(?i)(?:https?://)?meet\.google\.com/[a-z0-9-]+(?:\?\S*)?`),
+		repl: "[meeting link]",
+	},
+	{
+		name: "zoom",
+		re:   regexp.MustCompile(`(?i)(?:https?://)?(?:[a-z0-9-]+\.)?zoom\.us/\S+`),
+		repl: "[meeting link]",
+	},
+	{
+		name: "emails",
+		re:   regexp.MustCompile(`(?i)(?:mailto:)?[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}`),
+		repl: "[email]",
+	},
+	{
+		name: "passcodes",
+		re:   regexp.MustCompile(`(?i)\b(pin|passcode|password|meeting id|conference id)(\s*[:#=]\s*)(?:\d[\d ]*\d|\S+)`),
+		repl: "$1$2" + defaultRedaction,
+	},
+	{
+		// without a separator only numbers are values, so "Password reset" or "Pinboard" are kept
+		name: "passcodes",
+		re:   regexp.MustCompile(`(?i)\b(pin|passcode|password|meeting id|conference id)(\s+)\d(?:[\d ]*\d)?\b`),
+		repl: "$1$2" + defaultRedaction,
+	},
+	{
+		name: "phones",
+		// international numbers with a + and local numbers with a leading 0, dates and times do not match
+		re:   regexp.MustCompile(`(?:\+\d[\d ()./-]{6,}\d|\b0\d{2,5}[ /-]?\d{3,}(?:[ -]\d+)*\b)`),
+		repl: "[phone]",
+	},
+}
+
+type redactionRule struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+// redactor applies the rules of a Redaction.
+type redactor []redactionRule
+
+func newRedactor(r *Redaction) (redactor, error) {
+	var rd redactor
+	enabled := make(map[string]bool)
+	for _, name := range r.Builtin {
+		name = strings.ToLower(strings.TrimSpace(name))
+		found := false
+		for _, b := range builtinRedactions {
+			if b.name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("unknown built-in redaction `%s'", name)
+		}
+		enabled[name] = true
+	}
+	for _, b := range builtinRedactions {
+		if enabled[b.name] {
+			rd = append(rd, redactionRule{re: b.re, repl: b.repl})
+		}
+	}
+
+	for _, rule := range r.Rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid redaction pattern `%s'", rule.Pattern)
+		}
+		repl := rule.Replacement
+		if repl == "" {
+			repl = defaultRedaction
+		}
+		rd = append(rd, redactionRule{re: re, repl: repl})
+	}
+	return rd, nil
+}
+
+// Validate reports whether the rules of the redaction are valid.
+func (r *Redaction) Validate() error {
+	_, err := newRedactor(r)
+	return err
+}
+
+// apply redacts the summary, the description and the location of the event.
+func (rd redactor) apply(ev *Event) {
+	for _, rule := range rd {
+		ev.Summary = rule.re.ReplaceAllString(ev.Summary, rule.repl)
+		ev.Description = rule.re.ReplaceAllString(ev.Description, rule.repl)
+		ev.Location = rule.re.ReplaceAllString(ev.Location, rule.repl)
+	}
+}
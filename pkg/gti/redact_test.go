@@ -0,0 +1,77 @@
+package gti
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedaction(t *testing.T) {
+	rd, err := newRedactor(&Redaction{
+		Builtin: []string{"Emails", "phones", "meet", "zoom", "passcodes"},
+		Rules: []RedactionRule{
+			{Pattern: `https://intranet\.example\.com/\S+`, Replacement: "[internal link]"},
+			{Pattern: `(?i)project (\w+)`},
+		},
+	})
+	require.NoError(t, err)
+
+	ev := &Event{
+		Summary: "Project Apollo with alice@example.com",
+		Description: "Join: https://meet.google.com/abc-defg-hij\n" +
+			"Zoom: https://us02web.zoom.us/j/81234567890?pwd=abc123\n" +
+			"Dial-in: +49 30 1234 5678 PIN: 123 456 789#\n" +
+			"Mobile: 0171 1234567\n" +
+			"Passcode: s3cr3t\n" +
+			"Meeting ID 987 654 321, password=hunter2\n" +
+			"Pinboard review, Password reset discussion\n" +
+			"Notes: https://intranet.example.com/wiki/apollo\n" +
+			"Starts 2024-05-06 at 10:00, room 1.23",
+		Location: "mailto:bob@example.com",
+	}
+	rd.apply(ev)
+	require.Equal(t, "[redacted] with [email]", ev.Summary)
+	require.Equal(t, "Join: [meeting link]\n"+
+		"Zoom: [meeting link]\n"+
+		"Dial-in: [phone] PIN: [redacted]#\n"+
+		"Mobile: [phone]\n"+
+		"Passcode: [redacted]\n"+
+		"Meeting ID [redacted], password=[redacted]\n"+
+		"Pinboard review, Password reset discussion\n"+
+		"Notes: [internal link]\n"+
+		"Starts 2024-05-06 at 10:00, room 1.23", ev.Description)
+	require.Equal(t, "[email]", ev.Location)
+
+	require.Error(t, (&Redaction{Builtin: []string{"credit cards"}}).Validate())
+	require.Error(t, (&Redaction{Rules: []RedactionRule{{Pattern: "("}}}).Validate())
+	require.NoError(t, (&Redaction{}).Validate())
+}
+
+func TestWriteRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	config := &Config{
+		Format:         "ics",
+		Logger:         nopLogger(),
+		Writer:         &buf,
+		Version:        "test",
+		TemplateFields: TemplateFields{Description: "{{ .Description }} {{ .HTMLLink }}"},
+		Redaction:      Redaction{Builtin: []string{"passcodes"}, Rules: []RedactionRule{{Pattern: `https://calendar\.google\.com/\S+`}}},
+	}
+	start := time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC)
+	events := []Event{{
+		Summary:     "Call",
+		Description: "PIN: 1234",
+		HTMLLink:    "https://calendar.google.com/event?eid=1",
+		Start:       EventTime{Time: start},
+		End:         EventTime{Time: start.Add(time.Hour)},
+	}}
+	require.NoError(t, Write(config, &Calendar{ID: "work"}, events))
+	// the templates are applied before the redaction
+	require.Contains(t, buf.String(), "DESCRIPTION:PIN: [redacted] [redacted]\r\n")
+	require.Equal(t, "PIN: 1234", events[0].Description)
+
+	config.Redaction.Builtin = []string{"unknown"}
+	require.Error(t, Write(config, &Calendar{ID: "work"}, events))
+}
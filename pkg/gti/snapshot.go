@@ -0,0 +1,118 @@
+package gti
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// snapshot is the result of a fetch, it is used to render a calendar without access to the source.
+type snapshot struct {
+	TakenAt        time.Time `json:"taken_at"`
+	StartFrom      time.Time `json:"start_from"`
+	EndOn          time.Time `json:"end_on"`
+	KeepRecurrence bool      `json:"keep_recurrence,omitempty"`
+	Calendar       *Calendar `json:"calendar"`
+	Events         []Event   `json:"events"`
+}
+
+// snapshotPath returns the file the snapshot of the configured calendar is stored in.
+// The account and the kind of the source are part of the name, so the same calendar fetched by another
+// account or with freebusy.query does not share a snapshot.
+func snapshotPath(config *Config) string {
+	name := config.CalendarName
+	if config.CalendarID != "" {
+		name = config.CalendarID
+	}
+	kind := "google"
+	switch s := config.Source.(type) {
+	case nil, GoogleSource:
+	case GoogleFreeBusySource:
+		kind = "freebusy-query"
+	case ICSSource:
+		kind = "ics"
+		name = s.name()
+	case MergeSource:
+		kind = "merge"
+	default:
+		kind = fmt.Sprintf("%T", s)
+	}
+	sum := sha256.Sum256([]byte(kind + "\x00" + config.AccountEmail + "\x00" + name))
+	return filepath.Join(config.SnapshotDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+func writeSnapshot(config *Config, cal *Calendar, events []Event) error {
+	buf, err := json.MarshalIndent(&snapshot{
+		TakenAt:        time.Now().UTC(),
+		StartFrom:      config.StartFrom,
+		EndOn:          config.EndOn,
+		KeepRecurrence: config.KeepRecurrence,
+		Calendar:       cal,
+		Events:         events,
+	}, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to encode snapshot")
+	}
+
+	//nolint: gomnd // only the user should be able to read the events
+	if err := os.MkdirAll(config.SnapshotDir, 0o700); err != nil {
+		return errors.Wrapf(err, "unable to create `%s'", config.SnapshotDir)
+	}
+	path := snapshotPath(config)
+	tmp := path + ".tmp"
+	//nolint: gomnd // only the user should be able to read the events
+	if err := os.WriteFile(tmp, buf, 0o600); err != nil {
+		return errors.Wrapf(err, "unable to write `%s'", tmp)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return errors.Wrapf(err, "unable to rename `%s' to `%s'", tmp, path)
+	}
+	return nil
+}
+
+// readSnapshot returns the calendar and the events in the time range of the config from the snapshot.
+func readSnapshot(config *Config) (*Calendar, []Event, error) {
+	path := snapshotPath(config)
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to read snapshot `%s'", path)
+	}
+	var s snapshot
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to decode snapshot `%s'", path)
+	}
+	if s.Calendar == nil {
+		return nil, nil, errors.Errorf("snapshot `%s' has no calendar", path)
+	}
+	if s.KeepRecurrence != config.KeepRecurrence {
+		return nil, nil, errors.Errorf("snapshot `%s' was taken with keep recurrence %v", path, s.KeepRecurrence)
+	}
+
+	config.Logger.Debug().
+		Str("snapshot", path).
+		Time("taken_at", s.TakenAt).
+		Msg("using snapshot")
+	if config.StartFrom.Before(s.StartFrom) || config.EndOn.After(s.EndOn) {
+		// the default time range moves with the current time, so a snapshot rarely covers it exactly
+		config.Logger.Warn().
+			Str("snapshot", path).
+			Time("start_from", s.StartFrom).
+			Time("end_on", s.EndOn).
+			Msg("the snapshot does not cover the requested time range, events outside of it are missing")
+	}
+
+	events := make([]Event, 0, len(s.Events))
+	for i := range s.Events {
+		if inRange(&s.Events[i], config.StartFrom, config.EndOn) {
+			events = append(events, s.Events[i])
+		}
+	}
+	return s.Calendar, events, nil
+}
@@ -0,0 +1,94 @@
+package gti
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	server := newFakeServer(t, "calendar.json")
+	snapshotDir := t.TempDir()
+
+	export := func(format string, offline bool) string {
+		var buf bytes.Buffer
+		require.NoError(t, Export(&Config{
+			Format:       format,
+			AccountEmail: "me@example.com",
+			Logger:       nopLogger(),
+			StartFrom:    time.Date(2024, time.May, 6, 0, 0, 0, 0, time.UTC),
+			EndOn:        time.Date(2024, time.May, 13, 0, 0, 0, 0, time.UTC),
+			CalendarName: "Work",
+			Writer:       &buf,
+			Client:       server.Client(),
+			Version:      "test",
+			SnapshotDir:  snapshotDir,
+			Offline:      offline,
+		}))
+		return buf.String()
+	}
+
+	ics := export("ics", false)
+	jcal := export("jcal", false)
+	requests := len(server.Requests())
+
+	require.Equal(t, ics, export("ics", true))
+	require.Equal(t, jcal, export("jcal", true))
+	require.Len(t, server.Requests(), requests)
+
+	// a time range outside of the snapshot is warned about
+	var log bytes.Buffer
+	logger := zerolog.New(&log)
+	require.NoError(t, Export(&Config{
+		Format:       "ics",
+		AccountEmail: "me@example.com",
+		Logger:       &logger,
+		StartFrom:    time.Date(2024, time.May, 6, 0, 0, 0, 0, time.UTC),
+		EndOn:        time.Date(2024, time.May, 20, 0, 0, 0, 0, time.UTC),
+		CalendarName: "Work",
+		Writer:       &bytes.Buffer{},
+		SnapshotDir:  snapshotDir,
+		Offline:      true,
+	}))
+	require.Contains(t, log.String(), "the snapshot does not cover the requested time range")
+
+	err := Export(&Config{
+		Format:         "ics",
+		AccountEmail:   "me@example.com",
+		Logger:         nopLogger(),
+		CalendarName:   "Work",
+		Writer:         &bytes.Buffer{},
+		SnapshotDir:    snapshotDir,
+		Offline:        true,
+		KeepRecurrence: true,
+	})
+	require.Error(t, err)
+
+	err = Export(&Config{
+		Format:       "ics",
+		Logger:       nopLogger(),
+		CalendarName: "Missing",
+		Writer:       &bytes.Buffer{},
+		SnapshotDir:  snapshotDir,
+		Offline:      true,
+	})
+	require.Error(t, err)
+
+	for _, config := range []*Config{
+		// another account
+		{AccountEmail: "other@example.com"},
+		// the busy time of the same calendar
+		{AccountEmail: "me@example.com", Source: GoogleFreeBusySource{}},
+	} {
+		config.Format = "ics"
+		config.Logger = nopLogger()
+		config.CalendarName = "Work"
+		config.Writer = &bytes.Buffer{}
+		config.SnapshotDir = snapshotDir
+		config.Offline = true
+		require.Error(t, Export(config))
+	}
+}
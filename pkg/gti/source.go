@@ -0,0 +1,98 @@
+package gti
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Source provides the calendar and the events that should be exported.
+type Source interface {
+	// Fetch returns the calendar and its events in the time range of the config.
+	Fetch(ctx context.Context, config *Config) (*Calendar, []Event, error)
+}
+
+// GoogleSource reads the calendar with the CalendarName of the config from the Google Calendar API,
+// using the Client of the config.
+type GoogleSource struct{}
+
+// Fetch implements Source.
+func (GoogleSource) Fetch(ctx context.Context, config *Config) (*Calendar, []Event, error) {
+	return fetchGoogle(ctx, config)
+}
+
+// ICSSource reads an iCalendar file from a local Path or from an http(s) URL.
+// Recurring events are not expanded, they should be exported with KeepRecurrence.
+type ICSSource struct {
+	Path string
+	URL  string
+	// Client is used to download the URL, if nil http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Fetch implements Source.
+func (s ICSSource) Fetch(ctx context.Context, config *Config) (*Calendar, []Event, error) {
+	rc, err := s.open(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rc.Close()
+
+	cal, events, err := readICS(rc, config)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to read `%s'", s.name())
+	}
+	if cal.ID == "" {
+		cal.ID = s.name()
+	}
+	return cal, events, nil
+}
+
+func (s ICSSource) name() string {
+	if s.Path != "" {
+		return s.Path
+	}
+	return s.URL
+}
+
+func (s ICSSource) open(ctx context.Context) (io.ReadCloser, error) {
+	if s.Path != "" {
+		f, err := os.Open(s.Path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to open `%s'", s.Path)
+		}
+		return f, nil
+	}
+	if s.URL == "" {
+		return nil, errors.New("path or url is required")
+	}
+
+	u := s.URL
+	// webcal is http(s) with a different scheme, so calendar apps know they can subscribe
+	if strings.HasPrefix(u, "webcal://") {
+		u = "https://" + strings.TrimPrefix(u, "webcal://")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create request for `%s'", s.URL)
+	}
+	req.Header.Set("Accept", "text/calendar")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get `%s'", s.URL)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.Errorf("unable to get `%s': %s", s.URL, resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,243 @@
+package gti
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"X-WR-CALNAME:Work\r\n" +
+	"X-WR-TIMEZONE:Europe/Berlin\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:1@example.com\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20240501T100000\r\n" +
+	"DURATION:PT1H\r\n" +
+	"SUMMARY:Meeting\\, weekly\r\n" +
+	"RRULE:FREQ=WEEKLY\r\n" +
+	"EXDATE;TZID=Europe/Berlin:20240508T100000\r\n" +
+	"ORGANIZER;CN=Boss:mailto:boss@example.com\r\n" +
+	"ATTENDEE;ROLE=OPT-PARTICIPANT;PARTSTAT=TENTATIVE:mailto:me@example.com\r\n" +
+	"CLASS:CONFIDENTIAL\r\n" +
+	"LAST-MODIFIED:20240401T080000Z\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"TRIGGER:-PT15M\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:1@example.com\r\n" +
+	"RECURRENCE-ID;TZID=Europe/Berlin:20240515T100000\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20240515T120000\r\n" +
+	"DTEND;TZID=Europe/Berlin:20240515T130000\r\n" +
+	"SUMMARY:Meeting\\, moved\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:1@example.com\r\n" +
+	"RECURRENCE-ID;TZID=Europe/Berlin:20240522T100000\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20240522T100000\r\n" +
+	"DURATION:PT1H\r\n" +
+	"SUMMARY:Meeting\\, weekly\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:2@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20240601\r\n" +
+	"SUMMARY:Holiday\r\n" +
+	"TRANSP:TRANSPARENT\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:3@example.com\r\n" +
+	"DTSTART:20230101T100000Z\r\n" +
+	"DTEND:20230101T110000Z\r\n" +
+	"SUMMARY:Out of range\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestICSSource(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	config := &Config{
+		Logger:         nopLogger(),
+		AccountEmail:   "me@example.com",
+		StartFrom:      time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		EndOn:          time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		KeepRecurrence: true,
+	}
+
+	check := func(t *testing.T, cal *Calendar, events []Event) {
+		require.Equal(t, "Work", cal.Summary)
+		require.Equal(t, "Europe/Berlin", cal.TimeZone)
+		require.Len(t, events, 3)
+
+		require.Equal(t, Event{
+			ID:      "1@example.com",
+			UID:     "1@example.com",
+			Summary: "Meeting, weekly",
+			Start:   EventTime{Time: time.Date(2024, time.May, 1, 10, 0, 0, 0, berlin), TimeZone: "Europe/Berlin"},
+			End:     EventTime{Time: time.Date(2024, time.May, 1, 11, 0, 0, 0, berlin), TimeZone: "Europe/Berlin"},
+			// the cancelled instance is excluded from the series
+			Recurrence: []string{
+				"RRULE:FREQ=WEEKLY",
+				"EXDATE;TZID=Europe/Berlin:20240508T100000",
+				"EXDATE;TZID=Europe/Berlin:20240522T100000",
+			},
+			Organizer: &Person{Email: "boss@example.com", DisplayName: "Boss"},
+			Attendees: []Attendee{{
+				Person:         Person{Email: "me@example.com"},
+				Optional:       true,
+				ResponseStatus: ResponseTentative,
+				Self:           true,
+			}},
+			Reminders: []Reminder{{Method: ReminderDisplay, Before: 15 * time.Minute}},
+			Updated:   time.Date(2024, time.April, 1, 8, 0, 0, 0, time.UTC),
+		}, events[0])
+
+		require.Equal(t, "1@example.com_20240515T100000", events[1].ID)
+		require.Equal(t, "1@example.com", events[1].RecurringEventID)
+		require.Equal(t, time.Date(2024, time.May, 15, 10, 0, 0, 0, berlin), events[1].RecurrenceID.Time)
+
+		require.True(t, events[2].Start.AllDay)
+		require.Equal(t, time.Date(2024, time.June, 2, 0, 0, 0, 0, time.UTC), events[2].End.Time)
+		require.Equal(t, TransparencyTransparent, events[2].Transparency)
+	}
+
+	t.Run("path", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "calendar.ics")
+		require.NoError(t, os.WriteFile(path, []byte(testICS), 0o600))
+
+		cal, events, err := ICSSource{Path: path}.Fetch(context.Background(), config)
+		require.NoError(t, err)
+		require.Equal(t, path, cal.ID)
+		check(t, cal, events)
+	})
+
+	t.Run("url", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/calendar.ics" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "text/calendar")
+			_, _ = w.Write([]byte(testICS))
+		}))
+		defer server.Close()
+
+		cal, events, err := ICSSource{URL: server.URL + "/calendar.ics"}.Fetch(context.Background(), config)
+		require.NoError(t, err)
+		check(t, cal, events)
+
+		_, _, err = ICSSource{URL: server.URL + "/missing.ics"}.Fetch(context.Background(), config)
+		require.Error(t, err)
+	})
+}
+
+func TestExportICSSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calendar.ics")
+	require.NoError(t, os.WriteFile(path, []byte(testICS), 0o600))
+
+	var buf strings.Builder
+	require.NoError(t, Export(&Config{
+		Format:         "ics",
+		Logger:         nopLogger(),
+		StartFrom:      time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		EndOn:          time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Writer:         &buf,
+		KeepRecurrence: true,
+		Source:         ICSSource{Path: path},
+		HideFields:     HideFields{Attendees: true, Organizer: true},
+	}))
+	out := strings.ReplaceAll(buf.String(), "\r\n", "\n")
+	require.Contains(t, out, "BEGIN:VTIMEZONE\nTZID:Europe/Berlin\n")
+	require.Contains(t, out, "\nRRULE:FREQ=WEEKLY\nEXDATE;TZID=Europe/Berlin:20240508T100000\n")
+	require.Contains(t, out, "\nRECURRENCE-ID;TZID=Europe/Berlin:20240515T100000\n")
+	require.NotContains(t, out, "mailto:")
+	require.NotContains(t, out, "Out of range")
+}
+
+func TestICSSourceTimeZones(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	const ics = "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VTIMEZONE\r\n" +
+		"TZID:W. Europe Standard Time\r\n" +
+		"BEGIN:STANDARD\r\n" +
+		"DTSTART:16010101T030000\r\n" +
+		"TZOFFSETFROM:+0200\r\n" +
+		"TZOFFSETTO:+0100\r\n" +
+		"RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU\r\n" +
+		"END:STANDARD\r\n" +
+		"BEGIN:DAYLIGHT\r\n" +
+		"DTSTART:16010101T020000\r\n" +
+		"TZOFFSETFROM:+0100\r\n" +
+		"TZOFFSETTO:+0200\r\n" +
+		"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU\r\n" +
+		"END:DAYLIGHT\r\n" +
+		"END:VTIMEZONE\r\n" +
+		"BEGIN:VTIMEZONE\r\n" +
+		"TZID:Customized Time Zone\r\n" +
+		"BEGIN:STANDARD\r\n" +
+		"DTSTART:16010101T000000\r\n" +
+		"TZOFFSETFROM:+0900\r\n" +
+		"TZOFFSETTO:+0900\r\n" +
+		"END:STANDARD\r\n" +
+		"END:VTIMEZONE\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:1@example.com\r\n" +
+		"DTSTART;TZID=W. Europe Standard Time:20240501T100000\r\n" +
+		"DTEND;TZID=W. Europe Standard Time:20240501T110000\r\n" +
+		"RRULE:FREQ=WEEKLY\r\n" +
+		"EXDATE;TZID=W. Europe Standard Time:20240508T100000\r\n" +
+		"SUMMARY:Weekly\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:2@example.com\r\n" +
+		"DTSTART;TZID=Customized Time Zone:20240502T100000\r\n" +
+		"DTEND;TZID=Customized Time Zone:20240502T110000\r\n" +
+		"SUMMARY:Custom\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:3@example.com\r\n" +
+		"DTSTART;TZID=UTC:20240503T100000\r\n" +
+		"DTEND;TZID=UTC:20240503T110000\r\n" +
+		"SUMMARY:UTC\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	_, events, err := readICS(strings.NewReader(ics), &Config{
+		Logger:         nopLogger(),
+		StartFrom:      time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		EndOn:          time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		KeepRecurrence: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+
+	require.Equal(t, EventTime{Time: time.Date(2024, time.May, 1, 10, 0, 0, 0, berlin), TimeZone: "Europe/Berlin"}, events[0].Start)
+	require.Equal(t, []string{"RRULE:FREQ=WEEKLY", "EXDATE;TZID=Europe/Berlin:20240508T100000"}, events[0].Recurrence)
+
+	require.Equal(t, time.Date(2024, time.May, 2, 1, 0, 0, 0, time.UTC), events[1].Start.Time.UTC())
+	require.NotNil(t, loadLocation(events[1].Start.TimeZone))
+
+	// UTC is also a Windows name, it is not floating
+	require.Equal(t, time.Date(2024, time.May, 3, 10, 0, 0, 0, time.UTC), events[2].Start.Time.UTC())
+	require.Equal(t, "Etc/UTC", events[2].Start.TimeZone)
+}
+
+func TestWindowsTimeZones(t *testing.T) {
+	for windows, name := range windowsTimeZones {
+		_, err := time.LoadLocation(name)
+		require.NoError(t, err, windows)
+	}
+}
@@ -0,0 +1,220 @@
+package gti
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// eventStore holds all events of a calendar from the time it was fully synced,
+// so later exports only have to fetch the changes.
+type eventStore struct {
+	SyncToken string `json:"sync_token"`
+	// TimeMin is the start of the time range the store holds events for.
+	TimeMin      time.Time         `json:"time_min"`
+	SingleEvents bool              `json:"single_events"`
+	Events       []*calendar.Event `json:"events"`
+}
+
+// storeLocks serializes syncs of the same store.
+var storeLocks sync.Map
+
+func storePath(dir, calendarID string, singleEvents bool) string {
+	sum := sha256.Sum256([]byte(calendarID))
+	name := hex.EncodeToString(sum[:8])
+	if singleEvents {
+		name += "-single"
+	} else {
+		name += "-recurring"
+	}
+	return filepath.Join(dir, name+".json")
+}
+
+// loadEventStore reads the store, it returns nil if the store does not exist.
+func loadEventStore(path string) (*eventStore, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "unable to read `%s'", path)
+	}
+	var store eventStore
+	if err := json.Unmarshal(buf, &store); err != nil {
+		return nil, errors.Wrapf(err, "unable to decode `%s'", path)
+	}
+	return &store, nil
+}
+
+// save writes the store atomically.
+func (s *eventStore) save(path string) error {
+	buf, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode store")
+	}
+	//nolint: gomnd // only the user should be able to read the events
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return errors.Wrapf(err, "unable to create `%s'", filepath.Dir(path))
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "unable to create temporary file")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "unable to write `%s'", tmp.Name())
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "unable to close `%s'", tmp.Name())
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrapf(err, "unable to rename `%s' to `%s'", tmp.Name(), path)
+	}
+	return nil
+}
+
+// apply adds, updates and removes the changed events.
+// Cancelled instances of recurring events are kept, because they are exported as EXDATE.
+func (s *eventStore) apply(changes []*calendar.Event) {
+	index := make(map[string]int, len(s.Events))
+	for i, ev := range s.Events {
+		index[ev.Id] = i
+	}
+	for _, ev := range changes {
+		deleted := strings.EqualFold(ev.Status, "cancelled") && (s.SingleEvents || ev.RecurringEventId == "")
+		i, ok := index[ev.Id]
+		switch {
+		case ok && deleted:
+			s.Events[i] = nil
+		case ok:
+			s.Events[i] = ev
+		case !deleted:
+			index[ev.Id] = len(s.Events)
+			s.Events = append(s.Events, ev)
+		}
+	}
+	s.compact(nil)
+}
+
+// compact removes the events that are nil or for which drop returns true.
+func (s *eventStore) compact(drop func(ev *calendar.Event) bool) {
+	events := s.Events[:0]
+	for _, ev := range s.Events {
+		if ev == nil || (drop != nil && drop(ev)) {
+			continue
+		}
+		events = append(events, ev)
+	}
+	s.Events = events
+}
+
+// prune removes the single events that ended before t, they can not be part of an export anymore.
+func (s *eventStore) prune(t time.Time) {
+	if !t.After(s.TimeMin) {
+		return
+	}
+	s.compact(func(ev *calendar.Event) bool {
+		if len(ev.Recurrence) > 0 || ev.End == nil {
+			return false
+		}
+		end := convertGoogleEventTime(ev.End, "")
+		return !end.IsZero() && !end.Time.After(t)
+	})
+	s.TimeMin = t
+}
+
+// syncEvents returns the events in the time range of the config from the local store,
+// after fetching the changes since the last sync.
+// Without a store, or if Google does not accept the sync token anymore, all events are fetched again.
+func syncEvents(ctx context.Context, service *calendar.Service, calendarID string, config *Config) ([]*calendar.Event, error) {
+	singleEvents := !config.KeepRecurrence
+	path := storePath(config.StoreDir, calendarID, singleEvents)
+
+	mu, _ := storeLocks.LoadOrStore(path, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+
+	store, err := loadEventStore(path)
+	if err != nil {
+		config.Logger.Warn().Err(err).Str("calendar_id", calendarID).Msg("ignoring broken store")
+		store = nil
+	}
+	if store != nil && (store.SingleEvents != singleEvents || config.StartFrom.Before(store.TimeMin)) {
+		store = nil
+	}
+
+	if store != nil {
+		changes, syncToken, err := fetchEvents(ctx, service, calendarID, config, eventsQuery{syncToken: store.SyncToken})
+		var apiErr *googleapi.Error
+		switch {
+		case errors.As(err, &apiErr) && apiErr.Code == http.StatusGone:
+			config.Logger.Debug().Str("calendar_id", calendarID).Msg("sync token expired, doing a full sync")
+			store = nil
+		case err != nil:
+			return nil, err
+		default:
+			config.Logger.Debug().Str("calendar_id", calendarID).Msgf("synced %d changes", len(changes))
+			store.apply(changes)
+			store.SyncToken = syncToken
+		}
+	}
+
+	if store == nil {
+		// the time range can not be changed for incremental syncs, so the full sync has no end,
+		// otherwise events that move into the time range later would be missing
+		items, syncToken, err := fetchEvents(ctx, service, calendarID, config, eventsQuery{timeMin: config.StartFrom})
+		if err != nil {
+			return nil, err
+		}
+		store = &eventStore{TimeMin: config.StartFrom, SingleEvents: singleEvents, SyncToken: syncToken}
+		store.apply(items)
+	}
+
+	store.prune(config.StartFrom)
+	if store.SyncToken != "" {
+		if err := store.save(path); err != nil {
+			return nil, errors.Wrap(err, "unable to save store")
+		}
+	}
+
+	events := make([]*calendar.Event, 0, len(store.Events))
+	for _, ev := range store.Events {
+		if googleEventInRange(ev, config.StartFrom, config.EndOn) {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+// googleEventInRange reports whether the event happens between from and to.
+// Recurring events and cancelled instances are included as long as they start before to.
+func googleEventInRange(ev *calendar.Event, from, to time.Time) bool {
+	start := ev.Start
+	if start == nil {
+		start = ev.OriginalStartTime
+	}
+	if start == nil {
+		return false
+	}
+	startTime := convertGoogleEventTime(start, "")
+	if !to.IsZero() && !startTime.IsZero() && !startTime.Time.Before(to) {
+		return false
+	}
+	if len(ev.Recurrence) > 0 || ev.End == nil {
+		return true
+	}
+	end := convertGoogleEventTime(ev.End, "")
+	return end.IsZero() || from.IsZero() || end.Time.After(from)
+}
@@ -0,0 +1,75 @@
+package gti
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestSyncEvents(t *testing.T) {
+	server := newFakeServer(t, "calendar.json")
+	config := &Config{
+		Logger:       nopLogger(),
+		StartFrom:    time.Date(2024, time.May, 6, 0, 0, 0, 0, time.UTC),
+		EndOn:        time.Date(2024, time.May, 13, 0, 0, 0, 0, time.UTC),
+		CalendarName: "Work",
+		Client:       server.Client(),
+		StoreDir:     t.TempDir(),
+	}
+
+	summaries := func() []string {
+		_, events, err := Fetch(context.Background(), config)
+		require.NoError(t, err)
+		var result []string
+		for i := range events {
+			result = append(result, events[i].Summary)
+		}
+		return result
+	}
+	// eventRequests returns the sync tokens of the events.list requests since the last call
+	var seen int
+	eventRequests := func() []string {
+		var tokens []string
+		requests := server.Requests()
+		for _, r := range requests[seen:] {
+			if r.URL.Path == "/calendar/v3/calendars/work@example.com/events" && r.URL.Query().Get("pageToken") == "" {
+				tokens = append(tokens, r.URL.Query().Get("syncToken"))
+			}
+		}
+		seen = len(requests)
+		return tokens
+	}
+
+	require.Equal(t, []string{"Weekly sync", "Holiday", "Doctor", ""}, summaries())
+	require.Equal(t, []string{""}, eventRequests())
+
+	server.PutEvent("work@example.com", &calendar.Event{
+		Id:      "lunch",
+		Status:  "confirmed",
+		Summary: "Lunch",
+		Start:   &calendar.EventDateTime{DateTime: "2024-05-08T12:00:00+02:00"},
+		End:     &calendar.EventDateTime{DateTime: "2024-05-08T13:00:00+02:00"},
+	})
+	server.DeleteEvent("work@example.com", "doctor")
+
+	require.Equal(t, []string{"Weekly sync", "Holiday", "", "Lunch"}, summaries())
+	tokens := eventRequests()
+	require.Len(t, tokens, 1)
+	require.NotEmpty(t, tokens[0])
+
+	// a full sync is done if the sync token expired
+	server.ExpireSyncTokens()
+	require.Equal(t, []string{"Weekly sync", "Holiday", "", "Lunch"}, summaries())
+	tokens = eventRequests()
+	require.Len(t, tokens, 2)
+	require.NotEmpty(t, tokens[0])
+	require.Empty(t, tokens[1])
+
+	// a full sync is done if the time range starts before the stored events
+	config.StartFrom = config.StartFrom.AddDate(0, 0, -1)
+	summaries()
+	require.Equal(t, []string{""}, eventRequests())
+}
@@ -0,0 +1,160 @@
+package gti
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TemplateFields are text/template templates that rewrite fields of every event.
+// The templates are executed with a TemplateContext, empty templates and templates of hidden fields leave the
+// field as it is.
+type TemplateFields struct {
+	Summary     string `yaml:"summary" json:"summary,omitempty"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	Location    string `yaml:"location" json:"location,omitempty"`
+	Conference  string `yaml:"conference" json:"conference,omitempty"`
+}
+
+// TemplateContext is the data the TemplateFields are executed with.
+// The fields of the event (e.g. .Summary, .Description, .Start.Time, .HTMLLink or .ColorID) are the ones that
+// were fetched, before any field was overwritten. Hidden fields are empty.
+type TemplateContext struct {
+	*Event
+	// Calendar is the exported calendar, e.g. .Calendar.Summary.
+	Calendar *Calendar
+}
+
+// Validate reports whether the templates can be parsed.
+func (t *TemplateFields) Validate() error {
+	_, err := newFieldTemplates(t)
+	return err
+}
+
+// templateFuncs are the helper functions that can be used in the TemplateFields.
+var templateFuncs = template.FuncMap{
+	// truncate shortens s to at most n characters, the last one is an ellipsis if s was shortened.
+	"truncate": func(n int, s string) string {
+		r := []rune(s)
+		if n < 1 || len(r) <= n {
+			return s
+		}
+		return string(r[:n-1]) + "…"
+	},
+	// regexReplace replaces the matches of the regular expression in s, the replacement can use $1 for groups.
+	"regexReplace": func(expr, replacement, s string) (string, error) {
+		re, err := compileRegex(expr)
+		if err != nil {
+			return "", err
+		}
+		return re.ReplaceAllString(s, replacement), nil
+	},
+	"lower": strings.ToLower,
+	// formatTime formats a time with the Go layout, times of events are formatted in their timezone.
+	"formatTime": func(layout string, v interface{}) (string, error) {
+		switch t := v.(type) {
+		case EventTime:
+			return localTime(t).Format(layout), nil
+		case *EventTime:
+			if t == nil {
+				return "", nil
+			}
+			return localTime(*t).Format(layout), nil
+		case time.Time:
+			return t.Format(layout), nil
+		default:
+			return "", errors.Errorf("unable to format %T as time", v)
+		}
+	},
+}
+
+var regexCache sync.Map
+
+// compileRegex returns the compiled regular expression, it is cached because the templates are executed for
+// every event.
+func compileRegex(expr string) (*regexp.Regexp, error) {
+	if v, ok := regexCache.Load(expr); ok {
+		return v.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(expr, re)
+	return re, nil
+}
+
+// localTime returns the time of t in its timezone, all day events keep their date.
+func localTime(t EventTime) time.Time {
+	if loc := loadLocation(t.TimeZone); loc != nil && !t.AllDay {
+		return t.Time.In(loc)
+	}
+	return t.Time
+}
+
+// fieldTemplates are the parsed TemplateFields, templates that are not set are nil.
+type fieldTemplates struct {
+	summary     *template.Template
+	description *template.Template
+	location    *template.Template
+	conference  *template.Template
+}
+
+func newFieldTemplates(t *TemplateFields) (*fieldTemplates, error) {
+	var ft fieldTemplates
+	for _, field := range []struct {
+		name string
+		text string
+		tmpl **template.Template
+	}{
+		{"summary", t.Summary, &ft.summary},
+		{"description", t.Description, &ft.description},
+		{"location", t.Location, &ft.location},
+		{"conference", t.Conference, &ft.conference},
+	} {
+		if field.text == "" {
+			continue
+		}
+		tmpl, err := template.New(field.name).Funcs(templateFuncs).Parse(field.text)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse template of %s", field.name)
+		}
+		*field.tmpl = tmpl
+	}
+	return &ft, nil
+}
+
+// apply rewrites the fields of ev with the templates, executed with the fetched event without the hidden fields
+// and the calendar.
+func (ft *fieldTemplates) apply(cal *Calendar, hide *HideFields, fetched, ev *Event) error {
+	if ft.summary == nil && ft.description == nil && ft.location == nil && ft.conference == nil {
+		return nil
+	}
+	visible := fetched.clone()
+	hideEventFields(hide, visible)
+	ctx := &TemplateContext{Event: visible, Calendar: cal}
+	for _, field := range []struct {
+		tmpl   *template.Template
+		hidden bool
+		value  *string
+	}{
+		{ft.summary, false, &ev.Summary},
+		{ft.description, hide.Description, &ev.Description},
+		{ft.location, hide.Location, &ev.Location},
+		{ft.conference, hide.Conference, &ev.ConferenceURI},
+	} {
+		if field.tmpl == nil || field.hidden {
+			continue
+		}
+		var sb strings.Builder
+		if err := field.tmpl.Execute(&sb, ctx); err != nil {
+			return errors.Wrapf(err, "unable to execute template of %s", field.tmpl.Name())
+		}
+		*field.value = sb.String()
+	}
+	return nil
+}
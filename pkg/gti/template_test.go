@@ -0,0 +1,107 @@
+package gti
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldTemplates(t *testing.T) {
+	fetched := &Event{
+		Summary:     "Weekly sync with the whole team",
+		Description: "Secret agenda",
+		Location:    "Room 1",
+		Start:       EventTime{Time: time.Date(2024, time.May, 6, 8, 0, 0, 0, time.UTC), TimeZone: "Europe/Berlin"},
+		HTMLLink:    "https://calendar.google.com/event?eid=1",
+	}
+	// the summary was overwritten already, the templates still see the fetched one
+	ev := fetched.clone()
+	ev.Summary = "Busy"
+
+	templates, err := newFieldTemplates(&TemplateFields{
+		Summary:     "[{{ .Calendar.Summary }}] {{ .Summary | truncate 12 }}",
+		Description: "{{ .HTMLLink }} {{ .Description | lower }}",
+		Location:    `{{ regexReplace "Room (\\d+)" "R$1" .Location }} {{ formatTime "15:04 MST" .Start }}`,
+	})
+	require.NoError(t, err)
+	require.NoError(t, templates.apply(&Calendar{Summary: "Work"}, &HideFields{}, fetched, ev))
+	require.Equal(t, "[Work] Weekly sync…", ev.Summary)
+	require.Equal(t, "https://calendar.google.com/event?eid=1 secret agenda", ev.Description)
+	require.Equal(t, "R1 10:00 CEST", ev.Location)
+	require.Empty(t, ev.ConferenceURI)
+
+	// the regular expressions are compiled once
+	re, err := compileRegex(`Room (\d+)`)
+	require.NoError(t, err)
+	same, err := compileRegex(`Room (\d+)`)
+	require.NoError(t, err)
+	require.Same(t, re, same)
+
+	// without templates the event is not changed
+	templates, err = newFieldTemplates(&TemplateFields{})
+	require.NoError(t, err)
+	unchanged := fetched.clone()
+	require.NoError(t, templates.apply(&Calendar{}, &HideFields{}, fetched, unchanged))
+	require.Equal(t, fetched, unchanged)
+
+	require.Error(t, (&TemplateFields{Summary: "{{ .Summary"}).Validate())
+	require.Error(t, (&TemplateFields{Summary: "{{ unknown .Summary }}"}).Validate())
+
+	for _, text := range []string{
+		"{{ .Missing }}",
+		`{{ regexReplace "(" "" .Summary }}`,
+		`{{ formatTime "15:04" .Summary }}`,
+	} {
+		templates, err := newFieldTemplates(&TemplateFields{Summary: text})
+		require.NoError(t, err, text)
+		require.Error(t, templates.apply(&Calendar{}, &HideFields{}, fetched, fetched.clone()), text)
+	}
+}
+
+func TestFieldTemplatesHidden(t *testing.T) {
+	fetched := &Event{
+		Summary:     "Weekly sync",
+		Description: "secret pin",
+		Location:    "Room 1",
+	}
+	ev := fetched.clone()
+	ev.Description = ""
+
+	templates, err := newFieldTemplates(&TemplateFields{
+		Summary:     "{{ .Summary }} {{ .Description }}",
+		Description: "{{ .Description }}",
+		Location:    "{{ .Location }}",
+	})
+	require.NoError(t, err)
+	require.NoError(t, templates.apply(&Calendar{}, &HideFields{Description: true}, fetched, ev))
+	// the template of the hidden field is not applied, the other templates do not see it
+	require.Empty(t, ev.Description)
+	require.Equal(t, "Weekly sync ", ev.Summary)
+	require.Equal(t, "Room 1", ev.Location)
+	// the fetched event is not changed
+	require.Equal(t, "secret pin", fetched.Description)
+}
+
+func TestWriteTemplatesHidden(t *testing.T) {
+	var buf bytes.Buffer
+	config := &Config{
+		Format:         "ics",
+		Logger:         nopLogger(),
+		Writer:         &buf,
+		Version:        "test",
+		HideFields:     HideFields{Description: true},
+		TemplateFields: TemplateFields{Summary: "{{ .Summary }}{{ .Description }}", Description: "{{ .Description }}"},
+	}
+	start := time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC)
+	events := []Event{{
+		Summary:     "Call",
+		Description: "secret pin",
+		Start:       EventTime{Time: start},
+		End:         EventTime{Time: start.Add(time.Hour)},
+	}}
+	require.NoError(t, Write(config, &Calendar{ID: "work"}, events))
+	require.Contains(t, buf.String(), "SUMMARY:Call\r\n")
+	require.NotContains(t, buf.String(), "secret pin")
+}
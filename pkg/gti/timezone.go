@@ -0,0 +1,215 @@
+package gti
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	// embed the timezone database, so VTIMEZONE components can be built on systems without tzdata.
+	_ "time/tzdata"
+
+	"github.com/Eun/gcal-to-ics/pkg/ical"
+)
+
+var locationCache sync.Map
+
+// loadLocation returns the location for the specified timezone name.
+// It returns nil if the name is empty, unknown or UTC, in that case the times should be written in UTC.
+func loadLocation(name string) *time.Location {
+	if name == "" {
+		return nil
+	}
+	if v, ok := locationCache.Load(name); ok {
+		return v.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || loc == time.UTC {
+		loc = nil
+	}
+	locationCache.Store(name, loc)
+	return loc
+}
+
+// timeZoneUsage holds the range of times that are written for a timezone.
+type timeZoneUsage struct {
+	location *time.Location
+	min      time.Time
+	max      time.Time
+}
+
+var recurrenceTZIDRegex = regexp.MustCompile(`(?i);TZID=([^:;]+)`)
+
+// collectTimeZones returns the timezones that are used by the events, sorted by name.
+func collectTimeZones(events []*Event) []*timeZoneUsage {
+	usages := make(map[string]*timeZoneUsage)
+	add := func(name string, t time.Time) {
+		loc := loadLocation(name)
+		if loc == nil || t.IsZero() {
+			return
+		}
+		usage, ok := usages[loc.String()]
+		if !ok {
+			usages[loc.String()] = &timeZoneUsage{location: loc, min: t, max: t}
+			return
+		}
+		if t.Before(usage.min) {
+			usage.min = t
+		}
+		if t.After(usage.max) {
+			usage.max = t
+		}
+	}
+	addTime := func(t EventTime) {
+		if !t.AllDay {
+			add(t.TimeZone, t.Time)
+		}
+	}
+
+	for _, ev := range events {
+		addTime(ev.Start)
+		addTime(ev.End)
+		if ev.RecurrenceID != nil {
+			addTime(*ev.RecurrenceID)
+		}
+		for _, line := range ev.Recurrence {
+			for _, match := range recurrenceTZIDRegex.FindAllStringSubmatch(line, -1) {
+				add(strings.Trim(match[1], `"`), ev.Start.Time)
+			}
+		}
+	}
+
+	result := make([]*timeZoneUsage, 0, len(usages))
+	for _, usage := range usages {
+		result = append(result, usage)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].location.String() < result[j].location.String()
+	})
+	return result
+}
+
+type timeZoneTransition struct {
+	at         time.Time
+	offsetFrom int
+	offsetTo   int
+	name       string
+	isDST      bool
+}
+
+// findTimeZoneTransitions returns all offset changes of the location between from and to.
+func findTimeZoneTransitions(loc *time.Location, from, to time.Time) []timeZoneTransition {
+	var transitions []timeZoneTransition
+	const step = time.Hour * 24
+
+	_, offset := from.In(loc).Zone()
+	for t := from; t.Before(to); t = t.Add(step) {
+		next := t.Add(step)
+		_, nextOffset := next.In(loc).Zone()
+		if nextOffset == offset {
+			continue
+		}
+
+		// find the exact second of the transition
+		lo, hi := t, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2) //nolint: gomnd // half of the range
+			if _, o := mid.In(loc).Zone(); o == offset {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		name, _ := hi.In(loc).Zone()
+		transitions = append(transitions, timeZoneTransition{
+			at:         hi,
+			offsetFrom: offset,
+			offsetTo:   nextOffset,
+			name:       name,
+			isDST:      hi.In(loc).IsDST(),
+		})
+		offset = nextOffset
+	}
+	return transitions
+}
+
+// yearlyRule returns the RRULE for a transition that happens every year at the same weekday of the same month.
+func yearlyRule(tr *timeZoneTransition) string {
+	local := tr.at.Add(time.Duration(tr.offsetFrom) * time.Second).UTC()
+	weekday := strings.ToUpper(local.Weekday().String()[:2])
+	//nolint: gomnd // a week has 7 days
+	if local.AddDate(0, 0, 7).Month() != local.Month() {
+		return fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=-1%s", local.Month(), weekday)
+	}
+	//nolint: gomnd // a week has 7 days
+	return fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", local.Month(), (local.Day()-1)/7+1, weekday)
+}
+
+func writeTimeZone(enc ical.Writer, usage *timeZoneUsage) error {
+	// cover the complete years, and the year after the last usage, so that recurring events can be resolved
+	from := time.Date(usage.min.Year(), time.January, 1, 0, 0, 0, 0, usage.location).UTC()
+	to := time.Date(usage.max.Year()+2, time.January, 1, 0, 0, 0, 0, usage.location).UTC() //nolint: gomnd // one more year
+	transitions := findTimeZoneTransitions(usage.location, from, to)
+
+	name, offset := from.In(usage.location).Zone()
+	observances := append([]timeZoneTransition{{
+		at:         from,
+		offsetFrom: offset,
+		offsetTo:   offset,
+		name:       name,
+		isDST:      from.In(usage.location).IsDST(),
+	}}, transitions...)
+
+	// the last transitions of each kind get a yearly rule if they happened the same way in the previous year,
+	// so that times after the covered range can still be resolved
+	rules := make(map[int]string)
+	for _, isDST := range []bool{false, true} {
+		last, previous := -1, -1
+		for i := len(observances) - 1; i > 0; i-- {
+			if observances[i].isDST != isDST {
+				continue
+			}
+			if last == -1 {
+				last = i
+				continue
+			}
+			previous = i
+			break
+		}
+		if last == -1 || previous == -1 {
+			continue
+		}
+		l, p := &observances[last], &observances[previous]
+		rule := yearlyRule(l)
+		if rule == yearlyRule(p) &&
+			l.offsetFrom == p.offsetFrom &&
+			l.offsetTo == p.offsetTo &&
+			l.at.Add(time.Duration(l.offsetFrom)*time.Second).Format("150405") ==
+				p.at.Add(time.Duration(p.offsetFrom)*time.Second).Format("150405") {
+			rules[last] = rule
+		}
+	}
+
+	enc.Begin("VTIMEZONE")
+	enc.WriteProperty(&ical.Property{Name: "TZID", Value: usage.location.String()})
+	for i := range observances {
+		component := "STANDARD"
+		if observances[i].isDST {
+			component = "DAYLIGHT"
+		}
+		local := observances[i].at.Add(time.Duration(observances[i].offsetFrom) * time.Second).UTC()
+		enc.Begin(component)
+		enc.WriteProperty(&ical.Property{Name: "DTSTART", Value: ical.FormatDateTime(local)})
+		enc.WriteProperty(&ical.Property{Name: "TZOFFSETFROM", Value: ical.FormatUTCOffset(observances[i].offsetFrom)})
+		enc.WriteProperty(&ical.Property{Name: "TZOFFSETTO", Value: ical.FormatUTCOffset(observances[i].offsetTo)})
+		if rule, ok := rules[i]; ok {
+			enc.WriteProperty(&ical.Property{Name: "RRULE", Value: rule})
+		}
+		enc.WriteProperty(&ical.Property{Name: "TZNAME", Value: observances[i].name})
+		enc.End(component)
+	}
+	enc.End("VTIMEZONE")
+	return enc.Err()
+}
@@ -0,0 +1,60 @@
+package gti
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Eun/gcal-to-ics/pkg/ical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTimeZone(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = writeTimeZone(ical.NewEncoder(&buf), &timeZoneUsage{
+		location: loc,
+		min:      time.Date(2024, time.May, 1, 10, 0, 0, 0, loc),
+		max:      time.Date(2024, time.June, 1, 10, 0, 0, 0, loc),
+	})
+	require.NoError(t, err)
+	require.Equal(t, `BEGIN:VTIMEZONE
+TZID:Europe/Berlin
+BEGIN:STANDARD
+DTSTART:20240101T000000
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0100
+TZNAME:CET
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:20240331T020000
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0200
+TZNAME:CEST
+END:DAYLIGHT
+BEGIN:STANDARD
+DTSTART:20241027T030000
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+TZNAME:CET
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:20250330T020000
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0200
+RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU
+TZNAME:CEST
+END:DAYLIGHT
+BEGIN:STANDARD
+DTSTART:20251026T030000
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU
+TZNAME:CET
+END:STANDARD
+END:VTIMEZONE
+`, strings.ReplaceAll(buf.String(), "\r\n", "\n"))
+}
@@ -0,0 +1,150 @@
+package ical
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// maxLineLength is the maximum length of a line in octets, excluding the line break.
+const maxLineLength = 75
+
+// Writer writes components and their properties.
+// Implementations keep the first error that occurs and skip all following writes, use Err to check for it.
+type Writer interface {
+	// Begin starts a component, e.g. VEVENT.
+	Begin(component string)
+	// End ends a component.
+	End(component string)
+	// WriteProperty writes a property of the current component.
+	WriteProperty(p *Property)
+	// Err returns the first error that occurred while writing.
+	Err() error
+}
+
+// Encoder writes content lines to a writer.
+// The first error that occurs is kept and all following writes are skipped, use Err to check for it.
+type Encoder struct {
+	w   io.Writer
+	err error
+}
+
+// NewEncoder creates an Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Err returns the first error that occurred while writing.
+func (e *Encoder) Err() error {
+	return e.err
+}
+
+// Begin starts a component, e.g. VEVENT.
+func (e *Encoder) Begin(component string) {
+	e.WriteProperty(&Property{Name: "BEGIN", Type: TypeText, Value: strings.ToUpper(component)})
+}
+
+// End ends a component.
+func (e *Encoder) End(component string) {
+	e.WriteProperty(&Property{Name: "END", Type: TypeText, Value: strings.ToUpper(component)})
+}
+
+// WriteProperty writes the property as a folded content line.
+func (e *Encoder) WriteProperty(p *Property) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = io.WriteString(e.w, fold(FormatProperty(p)))
+	if e.err != nil {
+		e.err = errors.WithStack(e.err)
+	}
+}
+
+// FormatProperty returns the unfolded content line of the property, without line break.
+func FormatProperty(p *Property) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToUpper(p.Name))
+
+	valueType := p.ValueType()
+	if valueType != DefaultValueType(p.Name) && p.Param("VALUE") == "" {
+		sb.WriteString(";VALUE=")
+		sb.WriteString(string(valueType))
+	}
+
+	for _, param := range p.Params {
+		sb.WriteByte(';')
+		sb.WriteString(strings.ToUpper(param.Name))
+		sb.WriteByte('=')
+		for i, v := range param.Values {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(ParamValue(v))
+		}
+	}
+
+	sb.WriteByte(':')
+	if valueType == TypeText {
+		sb.WriteString(Text(p.Value))
+	} else {
+		sb.WriteString(p.Value)
+	}
+	return sb.String()
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	`;`, `\;`,
+	`,`, `\,`,
+)
+
+// Text escapes s to be used as a TEXT value.
+func Text(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// paramEscaper encodes the characters that cannot be part of a parameter value as specified in RFC 6868.
+var paramEscaper = strings.NewReplacer(
+	`^`, `^^`,
+	"\r\n", `^n`,
+	"\n", `^n`,
+	`"`, `^'`,
+)
+
+// ParamValue encodes s to be used as a parameter value, it will be quoted if necessary.
+func ParamValue(s string) string {
+	s = paramEscaper.Replace(s)
+	if strings.ContainsAny(s, ":;,") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// fold splits the line into multiple lines of at most 75 octets, without splitting UTF-8 characters.
+// Every line, including the last one, is terminated with CRLF.
+func fold(line string) string {
+	var sb strings.Builder
+	limit := maxLineLength
+	for len(line) > limit {
+		i := limit
+		for i > 0 && !utf8.RuneStart(line[i]) {
+			i--
+		}
+		// invalid UTF-8 without any rune start, cut it anywhere to make progress
+		if i == 0 {
+			i = limit
+		}
+		sb.WriteString(line[:i])
+		sb.WriteString("\r\n ")
+		line = line[i:]
+		// continuation lines start with a space that counts to the line length
+		limit = maxLineLength - 1
+	}
+	sb.WriteString(line)
+	sb.WriteString("\r\n")
+	return sb.String()
+}
@@ -0,0 +1,259 @@
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteProperty(t *testing.T) {
+	tests := []struct {
+		property Property
+		want     string
+	}{
+		// RFC 5545 3.3.11
+		{
+			property: Property{
+				Name:  "DESCRIPTION",
+				Value: "Project XYZ Final Review\nConference Room - 3B\nCome Prepared.",
+			},
+			want: "DESCRIPTION:Project XYZ Final Review\\nConference Room - 3B\\nCome Prepared.\r\n",
+		},
+		{
+			property: Property{
+				Name:  "SUMMARY",
+				Value: `Meeting; with, special\characters`,
+			},
+			want: "SUMMARY:Meeting\\; with\\, special\\\\characters\r\n",
+		},
+		// RFC 5545 3.2.4
+		{
+			property: Property{
+				Name: "ATTENDEE",
+				Params: []Param{
+					{Name: "delegated-to", Values: []string{"mailto:jdoe@example.com", "mailto:jqpublic@example.com"}},
+				},
+				Value: "mailto:jsmith@example.com",
+			},
+			want: "ATTENDEE;DELEGATED-TO=\"mailto:jdoe@example.com\",\"mailto:jqpublic@example.co\r\n" +
+				" m\":mailto:jsmith@example.com\r\n",
+		},
+		{
+			property: Property{
+				Name:   "ORGANIZER",
+				Params: []Param{{Name: "CN", Values: []string{"Smith, John: Jr."}}},
+				Value:  "mailto:jsmith@example.com",
+			},
+			want: "ORGANIZER;CN=\"Smith, John: Jr.\":mailto:jsmith@example.com\r\n",
+		},
+		// RFC 6868 4
+		{
+			property: Property{
+				Name:   "ATTENDEE",
+				Params: []Param{{Name: "CN", Values: []string{`George Herman "Babe" Ruth`}}},
+				Value:  "mailto:babe@example.com",
+			},
+			want: "ATTENDEE;CN=George Herman ^'Babe^' Ruth:mailto:babe@example.com\r\n",
+		},
+		// RFC 5545 3.8.2.4
+		{
+			property: Property{
+				Name:  "DTSTART",
+				Type:  TypeDate,
+				Value: "19970714",
+			},
+			want: "DTSTART;VALUE=DATE:19970714\r\n",
+		},
+		{
+			property: Property{
+				Name:   "DTSTART",
+				Params: []Param{{Name: "TZID", Values: []string{"America/New_York"}}},
+				Value:  "19980119T020000",
+			},
+			want: "DTSTART;TZID=America/New_York:19980119T020000\r\n",
+		},
+		// RFC 5545 3.8.5.3
+		{
+			property: Property{
+				Name:  "RRULE",
+				Value: "FREQ=WEEKLY;COUNT=10;WKST=SU;BYDAY=TU,TH",
+			},
+			want: "RRULE:FREQ=WEEKLY;COUNT=10;WKST=SU;BYDAY=TU,TH\r\n",
+		},
+		// RFC 5545 3.1
+		{
+			property: Property{
+				Name:  "DESCRIPTION",
+				Value: "This is a long description that exists on a long line. It will be folded after 75 octets.",
+			},
+			want: "DESCRIPTION:This is a long description that exists on a long line. It will \r\n" +
+				" be folded after 75 octets.\r\n",
+		},
+	}
+	for i, tt := range tests {
+		tt := tt
+		t.Run(fmt.Sprintf("test #%d", i), func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.WriteProperty(&tt.property)
+			require.NoError(t, enc.Err())
+			require.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestFold(t *testing.T) {
+	tests := []string{
+		strings.Repeat("a", 200),
+		"SUMMARY:" + strings.Repeat("ä", 100),
+		"SUMMARY:" + strings.Repeat("😀", 50),
+	}
+	for i, line := range tests {
+		line := line
+		t.Run(fmt.Sprintf("test #%d", i), func(t *testing.T) {
+			t.Parallel()
+			folded := fold(line)
+			require.True(t, strings.HasSuffix(folded, "\r\n"))
+			lines := strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n")
+			for j, l := range lines {
+				require.LessOrEqual(t, len(l), maxLineLength)
+				require.True(t, utf8.ValidString(strings.TrimPrefix(l, " ")), "line %d is not valid utf8", j)
+				if j > 0 {
+					require.True(t, strings.HasPrefix(l, " "))
+				}
+			}
+			// unfolding must return the original line
+			require.Equal(t, line, strings.ReplaceAll(strings.TrimSuffix(folded, "\r\n"), "\r\n ", ""))
+		})
+	}
+}
+
+func TestFoldInvalidUTF8(t *testing.T) {
+	// continuation bytes without a rune start must not stop the folding
+	line := "SUMMARY:" + strings.Repeat("\x80", 200)
+	folded := fold(line)
+	lines := strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n")
+	for _, l := range lines {
+		require.LessOrEqual(t, len(l), maxLineLength)
+	}
+	require.Equal(t, line, strings.ReplaceAll(strings.TrimSuffix(folded, "\r\n"), "\r\n ", ""))
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{in: 0, want: "PT0S"},
+		{in: -10 * time.Minute, want: "-PT10M"},
+		{in: 90 * time.Second, want: "PT1M30S"},
+		{in: time.Hour, want: "PT1H"},
+		{in: 24 * time.Hour, want: "P1D"},
+		{in: -26 * time.Hour, want: "-P1DT2H"},
+		{in: 7 * 24 * time.Hour, want: "P1W"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, FormatDuration(tt.in))
+	}
+}
+
+func TestFormatUTCOffset(t *testing.T) {
+	require.Equal(t, "+0100", FormatUTCOffset(3600))
+	require.Equal(t, "-0530", FormatUTCOffset(-19800))
+	require.Equal(t, "+0000", FormatUTCOffset(0))
+	require.Equal(t, "+005328", FormatUTCOffset(3208))
+}
+
+func TestParseUTCOffset(t *testing.T) {
+	for _, offset := range []int{3600, -19800, 0, 3208} {
+		got, err := ParseUTCOffset(FormatUTCOffset(offset))
+		require.NoError(t, err)
+		require.Equal(t, offset, got)
+	}
+	for _, in := range []string{"", "0100", "+01", "+01:00", "+0a00"} {
+		_, err := ParseUTCOffset(in)
+		require.Error(t, err, in)
+	}
+}
+
+func TestParseProperty(t *testing.T) {
+	p, err := ParseProperty(`ATTENDEE;CN="Smith, John";PARTSTAT=ACCEPTED:mailto:jsmith@example.com`)
+	require.NoError(t, err)
+	require.Equal(t, &Property{
+		Name: "ATTENDEE",
+		Params: []Param{
+			{Name: "CN", Values: []string{"Smith, John"}},
+			{Name: "PARTSTAT", Values: []string{"ACCEPTED"}},
+		},
+		Value: "mailto:jsmith@example.com",
+	}, p)
+
+	p, err = ParseProperty(`EXDATE;VALUE=DATE:19960402,19960403`)
+	require.NoError(t, err)
+	require.Equal(t, &Property{Name: "EXDATE", Type: TypeDate, Value: "19960402,19960403"}, p)
+
+	p, err = ParseProperty(`DESCRIPTION:Project XYZ Final Review\nConference Room - 3B\, 1st floor`)
+	require.NoError(t, err)
+	require.Equal(t, "Project XYZ Final Review\nConference Room - 3B, 1st floor", p.Value)
+
+	_, err = ParseProperty(`DESCRIPTION`)
+	require.Error(t, err)
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{in: "PT0S", want: 0},
+		{in: "-PT10M", want: -10 * time.Minute},
+		{in: "PT1M30S", want: 90 * time.Second},
+		{in: "+P1D", want: 24 * time.Hour},
+		{in: "-P1DT2H", want: -26 * time.Hour},
+		{in: "P1W", want: 7 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		d, err := ParseDuration(tt.in)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, d)
+		if tt.in[0] != '+' {
+			require.Equal(t, tt.in, FormatDuration(d))
+		}
+	}
+
+	for _, in := range []string{"", "P", "PT", "10M", "PT10D", "P1H"} {
+		_, err := ParseDuration(in)
+		require.Error(t, err, in)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	c, err := Decode(strings.NewReader("BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiu\r\n" +
+		" smod tempor\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"TRIGGER:-PT10M\r\n" +
+		"END:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"))
+	require.NoError(t, err)
+	require.Equal(t, "VCALENDAR", c.Name)
+	require.Equal(t, "2.0", c.PropertyValue("VERSION"))
+	require.Len(t, c.Components, 1)
+	require.Equal(t, "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor",
+		c.Components[0].PropertyValue("SUMMARY"))
+	require.Equal(t, "-PT10M", c.Components[0].Components[0].PropertyValue("TRIGGER"))
+
+	_, err = Decode(strings.NewReader("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n"))
+	require.Error(t, err)
+	_, err = Decode(strings.NewReader("BEGIN:VCALENDAR\r\n"))
+	require.Error(t, err)
+}
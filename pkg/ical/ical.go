@@ -0,0 +1,163 @@
+// Package ical implements the content lines of the iCalendar format as specified in RFC 5545.
+package ical
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ValueType is the type of a property value.
+type ValueType string
+
+const (
+	TypeText       ValueType = "TEXT"
+	TypeDate       ValueType = "DATE"
+	TypeDateTime   ValueType = "DATE-TIME"
+	TypeDuration   ValueType = "DURATION"
+	TypeUTCOffset  ValueType = "UTC-OFFSET"
+	TypeCalAddress ValueType = "CAL-ADDRESS"
+	TypeURI        ValueType = "URI"
+	TypeRecur      ValueType = "RECUR"
+	TypeInteger    ValueType = "INTEGER"
+	TypePeriod     ValueType = "PERIOD"
+)
+
+var defaultValueTypes = map[string]ValueType{
+	"DTSTART":       TypeDateTime,
+	"DTEND":         TypeDateTime,
+	"DTSTAMP":       TypeDateTime,
+	"CREATED":       TypeDateTime,
+	"LAST-MODIFIED": TypeDateTime,
+	"RECURRENCE-ID": TypeDateTime,
+	"EXDATE":        TypeDateTime,
+	"RDATE":         TypeDateTime,
+	"RRULE":         TypeRecur,
+	"EXRULE":        TypeRecur,
+	"TRIGGER":       TypeDuration,
+	"DURATION":      TypeDuration,
+	"TZOFFSETFROM":  TypeUTCOffset,
+	"TZOFFSETTO":    TypeUTCOffset,
+	"ORGANIZER":     TypeCalAddress,
+	"ATTENDEE":      TypeCalAddress,
+	"URL":           TypeURI,
+	"TZURL":         TypeURI,
+	"FREEBUSY":      TypePeriod,
+	"SEQUENCE":      TypeInteger,
+	"PRIORITY":      TypeInteger,
+	"REPEAT":        TypeInteger,
+}
+
+// DefaultValueType returns the value type a property has if no VALUE parameter is specified.
+func DefaultValueType(name string) ValueType {
+	if t, ok := defaultValueTypes[strings.ToUpper(name)]; ok {
+		return t
+	}
+	return TypeText
+}
+
+// Param is a property parameter, e.g. CN in ATTENDEE;CN=John:mailto:john@example.com.
+type Param struct {
+	Name   string
+	Values []string
+}
+
+// Property is a single content line.
+// Value holds the unescaped value for TEXT properties, and the encoded value for all other types.
+// Multiple values are separated by a comma.
+type Property struct {
+	Name   string
+	Params []Param
+	// Type is the value type, if empty the default value type of the property is used.
+	Type  ValueType
+	Value string
+}
+
+// ValueType returns the value type of the property.
+func (p *Property) ValueType() ValueType {
+	if p.Type != "" {
+		return p.Type
+	}
+	return DefaultValueType(p.Name)
+}
+
+// Param returns the first value of the specified parameter.
+func (p *Property) Param(name string) string {
+	for _, param := range p.Params {
+		if strings.EqualFold(param.Name, name) && len(param.Values) > 0 {
+			return param.Values[0]
+		}
+	}
+	return ""
+}
+
+const (
+	dateFormat        = "20060102"
+	dateTimeFormat    = "20060102T150405"
+	dateTimeFormatUTC = "20060102T150405Z"
+)
+
+// FormatDate formats t as a DATE value.
+func FormatDate(t time.Time) string {
+	return t.Format(dateFormat)
+}
+
+// FormatDateTime formats t as a DATE-TIME value in local time of t's location.
+func FormatDateTime(t time.Time) string {
+	return t.Format(dateTimeFormat)
+}
+
+// FormatDateTimeUTC formats t as a DATE-TIME value in UTC.
+func FormatDateTimeUTC(t time.Time) string {
+	return t.UTC().Format(dateTimeFormatUTC)
+}
+
+// FormatDuration formats d as a DURATION value, e.g. -PT10M.
+func FormatDuration(d time.Duration) string {
+	var sb strings.Builder
+	if d < 0 {
+		sb.WriteByte('-')
+		d = -d
+	}
+	sb.WriteByte('P')
+
+	const day = time.Hour * 24
+	const week = day * 7
+	if d%week == 0 && d != 0 {
+		fmt.Fprintf(&sb, "%dW", d/week)
+		return sb.String()
+	}
+	if d >= day {
+		fmt.Fprintf(&sb, "%dD", d/day)
+		d %= day
+	}
+	if d == 0 && sb.Len() > 2 {
+		return sb.String()
+	}
+	sb.WriteByte('T')
+	if h := d / time.Hour; h > 0 {
+		fmt.Fprintf(&sb, "%dH", h)
+	}
+	if m := d % time.Hour / time.Minute; m > 0 {
+		fmt.Fprintf(&sb, "%dM", m)
+	}
+	if s := d % time.Minute / time.Second; s > 0 || d < time.Minute {
+		fmt.Fprintf(&sb, "%dS", s)
+	}
+	return sb.String()
+}
+
+// FormatUTCOffset formats the offset in seconds as a UTC-OFFSET value, e.g. +0100.
+func FormatUTCOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	//nolint: gomnd // convert seconds to hours, minutes and seconds
+	hours, minutes, seconds := offset/3600, offset/60%60, offset%60
+	if seconds != 0 {
+		return fmt.Sprintf("%c%02d%02d%02d", sign, hours, minutes, seconds)
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, hours, minutes)
+}
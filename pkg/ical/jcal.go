@@ -0,0 +1,222 @@
+package ical
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// JCalEncoder writes components in the jCal format as specified in RFC 7265.
+type JCalEncoder struct {
+	w     io.Writer
+	err   error
+	stack []jcalComponent
+}
+
+type jcalComponent struct {
+	hasProperties    bool
+	propertiesClosed bool
+}
+
+// NewJCalEncoder creates a JCalEncoder that writes to w.
+func NewJCalEncoder(w io.Writer) *JCalEncoder {
+	return &JCalEncoder{w: w}
+}
+
+// Err returns the first error that occurred while writing.
+func (e *JCalEncoder) Err() error {
+	return e.err
+}
+
+// Begin starts a component, e.g. VEVENT.
+func (e *JCalEncoder) Begin(component string) {
+	if n := len(e.stack); n > 0 {
+		if e.stack[n-1].propertiesClosed {
+			e.write(",")
+		} else {
+			e.write("],[")
+			e.stack[n-1].propertiesClosed = true
+		}
+	}
+	e.write("[")
+	e.writeJSON(strings.ToLower(component))
+	e.write(",[")
+	e.stack = append(e.stack, jcalComponent{})
+}
+
+// End ends a component.
+func (e *JCalEncoder) End(string) {
+	n := len(e.stack)
+	if n == 0 {
+		if e.err == nil {
+			e.err = errors.New("end without begin")
+		}
+		return
+	}
+	if e.stack[n-1].propertiesClosed {
+		e.write("]]")
+	} else {
+		e.write("],[]]")
+	}
+	e.stack = e.stack[:n-1]
+}
+
+// WriteProperty writes a property of the current component.
+func (e *JCalEncoder) WriteProperty(p *Property) {
+	n := len(e.stack)
+	if n == 0 {
+		if e.err == nil {
+			e.err = errors.New("property outside of a component")
+		}
+		return
+	}
+	if e.stack[n-1].hasProperties {
+		e.write(",")
+	}
+	e.stack[n-1].hasProperties = true
+	e.writeJSON(JCalProperty(p))
+}
+
+func (e *JCalEncoder) write(s string) {
+	if e.err != nil {
+		return
+	}
+	if _, err := io.WriteString(e.w, s); err != nil {
+		e.err = errors.WithStack(err)
+	}
+}
+
+func (e *JCalEncoder) writeJSON(v interface{}) {
+	if e.err != nil {
+		return
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		e.err = errors.WithStack(err)
+		return
+	}
+	e.write(string(buf))
+}
+
+// JCalProperty returns the jCal representation of the property: [name, parameters, type, values...].
+func JCalProperty(p *Property) []interface{} {
+	params := make(map[string]interface{}, len(p.Params))
+	for _, param := range p.Params {
+		if strings.EqualFold(param.Name, "VALUE") {
+			continue
+		}
+		if len(param.Values) == 1 {
+			params[strings.ToLower(param.Name)] = param.Values[0]
+			continue
+		}
+		params[strings.ToLower(param.Name)] = param.Values
+	}
+
+	valueType := p.ValueType()
+	result := []interface{}{strings.ToLower(p.Name), params, strings.ToLower(string(valueType))}
+	switch valueType {
+	case TypeText, TypeCalAddress, TypeURI:
+		result = append(result, p.Value)
+	case TypeDate, TypeDateTime, TypePeriod, TypeDuration:
+		for _, v := range strings.Split(p.Value, ",") {
+			result = append(result, extendedDateTime(v))
+		}
+	case TypeUTCOffset:
+		result = append(result, extendedUTCOffset(p.Value))
+	case TypeInteger:
+		result = append(result, jcalInteger(p.Value))
+	case TypeRecur:
+		result = append(result, jcalRecur(p.Value))
+	default:
+		result = append(result, p.Value)
+	}
+	return result
+}
+
+// extendedDateTime converts a DATE, DATE-TIME, DURATION or PERIOD value to the extended format used by jCal and xCal,
+// e.g. 20240101T100000Z becomes 2024-01-01T10:00:00Z.
+func extendedDateTime(s string) string {
+	if start, end, ok := strings.Cut(s, "/"); ok {
+		return extendedDateTime(start) + "/" + extendedDateTime(end)
+	}
+	if strings.HasPrefix(s, "P") || strings.HasPrefix(s, "-P") || strings.HasPrefix(s, "+P") {
+		return s
+	}
+	date, tm, hasTime := strings.Cut(s, "T")
+	//nolint: gomnd // YYYYMMDD
+	if len(date) != 8 {
+		return s
+	}
+	result := date[:4] + "-" + date[4:6] + "-" + date[6:]
+	if !hasTime {
+		return result
+	}
+	suffix := ""
+	if strings.HasSuffix(tm, "Z") {
+		suffix = "Z"
+		tm = tm[:len(tm)-1]
+	}
+	//nolint: gomnd // HHMMSS
+	if len(tm) != 6 {
+		return s
+	}
+	return result + "T" + tm[:2] + ":" + tm[2:4] + ":" + tm[4:] + suffix
+}
+
+// extendedUTCOffset converts an UTC-OFFSET value to the extended format used by jCal and xCal,
+// e.g. +0100 becomes +01:00.
+func extendedUTCOffset(s string) string {
+	//nolint: gomnd // sign and HHMM or HHMMSS
+	switch len(s) {
+	case 5:
+		return s[:3] + ":" + s[3:]
+	case 7:
+		return s[:3] + ":" + s[3:5] + ":" + s[5:]
+	}
+	return s
+}
+
+func jcalInteger(s string) interface{} {
+	if i, err := strconv.Atoi(s); err == nil {
+		return i
+	}
+	return s
+}
+
+// jcalRecur converts a RECUR value to an object, e.g. FREQ=WEEKLY;BYDAY=MO,TU becomes {"freq":"WEEKLY","byday":["MO","TU"]}.
+func jcalRecur(s string) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(key)
+		switch key {
+		case "until":
+			result[key] = extendedDateTime(value)
+		case "count", "interval":
+			result[key] = jcalInteger(value)
+		case "freq", "wkst", "byday":
+			result[key] = jcalList(value, func(s string) interface{} { return s })
+		default:
+			result[key] = jcalList(value, jcalInteger)
+		}
+	}
+	return result
+}
+
+func jcalList(s string, convert func(string) interface{}) interface{} {
+	parts := strings.Split(s, ",")
+	if len(parts) == 1 {
+		return convert(parts[0])
+	}
+	list := make([]interface{}, len(parts))
+	for i := range parts {
+		list[i] = convert(parts[i])
+	}
+	return list
+}
@@ -0,0 +1,73 @@
+package ical
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJCalEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewJCalEncoder(&buf)
+	enc.Begin("VCALENDAR")
+	enc.WriteProperty(&Property{Name: "VERSION", Value: "2.0"})
+	enc.WriteProperty(&Property{Name: "PRODID", Value: "-//Example Inc.//Example Calendar//EN"})
+	enc.Begin("VTIMEZONE")
+	enc.WriteProperty(&Property{Name: "TZID", Value: "Europe/Berlin"})
+	enc.Begin("STANDARD")
+	enc.WriteProperty(&Property{Name: "TZOFFSETTO", Value: "+0100"})
+	enc.End("STANDARD")
+	enc.End("VTIMEZONE")
+	enc.Begin("VEVENT")
+	enc.WriteProperty(&Property{Name: "DTSTART", Type: TypeDate, Value: "20080205"})
+	enc.WriteProperty(&Property{
+		Name:   "DTEND",
+		Params: []Param{{Name: "TZID", Values: []string{"Europe/Berlin"}}},
+		Value:  "20080206T100000",
+	})
+	enc.WriteProperty(&Property{Name: "RRULE", Value: "FREQ=WEEKLY;COUNT=10;BYDAY=TU,TH"})
+	enc.WriteProperty(&Property{Name: "EXDATE", Value: "20080212T100000Z,20080214T100000Z"})
+	enc.WriteProperty(&Property{Name: "SUMMARY", Value: "Event #2, bis"})
+	enc.WriteProperty(&Property{
+		Name:   "ATTENDEE",
+		Params: []Param{{Name: "CN", Values: []string{"Smith, John"}}},
+		Value:  "mailto:jsmith@example.com",
+	})
+	enc.Begin("VALARM")
+	enc.WriteProperty(&Property{Name: "TRIGGER", Value: "-PT10M"})
+	enc.End("VALARM")
+	enc.End("VEVENT")
+	enc.End("VCALENDAR")
+	require.NoError(t, enc.Err())
+
+	require.JSONEq(t, `["vcalendar",
+  [
+    ["version", {}, "text", "2.0"],
+    ["prodid", {}, "text", "-//Example Inc.//Example Calendar//EN"]
+  ],
+  [
+    ["vtimezone",
+      [
+        ["tzid", {}, "text", "Europe/Berlin"]
+      ],
+      [
+        ["standard", [["tzoffsetto", {}, "utc-offset", "+01:00"]], []]
+      ]
+    ],
+    ["vevent",
+      [
+        ["dtstart", {}, "date", "2008-02-05"],
+        ["dtend", {"tzid": "Europe/Berlin"}, "date-time", "2008-02-06T10:00:00"],
+        ["rrule", {}, "recur", {"freq": "WEEKLY", "count": 10, "byday": ["TU", "TH"]}],
+        ["exdate", {}, "date-time", "2008-02-12T10:00:00Z", "2008-02-14T10:00:00Z"],
+        ["summary", {}, "text", "Event #2, bis"],
+        ["attendee", {"cn": "Smith, John"}, "cal-address", "mailto:jsmith@example.com"]
+      ],
+      [
+        ["valarm", [["trigger", {}, "duration", "-PT10M"]], []]
+      ]
+    ]
+  ]
+]`, buf.String())
+}
@@ -0,0 +1,291 @@
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Component is a decoded component, e.g. VCALENDAR or VEVENT.
+type Component struct {
+	Name       string
+	Properties []*Property
+	Components []*Component
+}
+
+// Property returns the first property with the specified name, or nil if there is none.
+func (c *Component) Property(name string) *Property {
+	for _, p := range c.Properties {
+		if strings.EqualFold(p.Name, name) {
+			return p
+		}
+	}
+	return nil
+}
+
+// PropertyValue returns the value of the first property with the specified name.
+func (c *Component) PropertyValue(name string) string {
+	if p := c.Property(name); p != nil {
+		return p.Value
+	}
+	return ""
+}
+
+// Decode reads a single top level component, usually a VCALENDAR.
+func Decode(r io.Reader) (*Component, error) {
+	var stack []*Component
+	var root *Component
+
+	handle := func(line string) error {
+		if line == "" {
+			return nil
+		}
+		p, err := ParseProperty(line)
+		if err != nil {
+			return err
+		}
+		switch p.Name {
+		case "BEGIN":
+			c := &Component{Name: strings.ToUpper(p.Value)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, c)
+			} else if root != nil {
+				return errors.New("multiple top level components")
+			} else {
+				root = c
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(p.Value) {
+				return errors.Errorf("unexpected END:%s", p.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return errors.Errorf("property %s outside of a component", p.Name)
+			}
+			c := stack[len(stack)-1]
+			c.Properties = append(c.Properties, p)
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	//nolint: gomnd // allow long content lines
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var line strings.Builder
+	for scanner.Scan() {
+		s := strings.TrimRight(scanner.Text(), "\r")
+		// unfold continuation lines
+		if s != "" && (s[0] == ' ' || s[0] == '\t') {
+			line.WriteString(s[1:])
+			continue
+		}
+		if err := handle(line.String()); err != nil {
+			return nil, err
+		}
+		line.Reset()
+		line.WriteString(s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "unable to read")
+	}
+	if err := handle(line.String()); err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, errors.New("no component found")
+	}
+	if len(stack) > 0 {
+		return nil, errors.Errorf("missing END:%s", stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+// ParseProperty parses an unfolded content line.
+// TEXT values are unescaped, a VALUE parameter is stored in the Type of the property.
+func ParseProperty(line string) (*Property, error) {
+	line = strings.TrimRight(line, "\r\n")
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return nil, errors.Errorf("malformed content line `%s'", line)
+	}
+
+	p := &Property{Name: strings.ToUpper(line[:i])}
+	rest := line[i:]
+	for rest != "" && rest[0] == ';' {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, errors.Errorf("malformed parameter in content line `%s'", line)
+		}
+		param := Param{Name: strings.ToUpper(rest[:eq])}
+		rest = rest[eq+1:]
+		for {
+			var value string
+			if rest != "" && rest[0] == '"' {
+				end := strings.IndexByte(rest[1:], '"')
+				if end == -1 {
+					return nil, errors.Errorf("unterminated quoted parameter in content line `%s'", line)
+				}
+				value = rest[1 : end+1]
+				rest = rest[end+2:]
+			} else {
+				end := strings.IndexAny(rest, ",;:")
+				if end == -1 {
+					return nil, errors.Errorf("missing value in content line `%s'", line)
+				}
+				value = rest[:end]
+				rest = rest[end:]
+			}
+			param.Values = append(param.Values, paramUnescaper.Replace(value))
+			if rest == "" || rest[0] != ',' {
+				break
+			}
+			rest = rest[1:]
+		}
+
+		if param.Name == "VALUE" && len(param.Values) > 0 {
+			p.Type = ValueType(strings.ToUpper(param.Values[0]))
+			continue
+		}
+		p.Params = append(p.Params, param)
+	}
+
+	if rest == "" || rest[0] != ':' {
+		return nil, errors.Errorf("missing value in content line `%s'", line)
+	}
+	p.Value = rest[1:]
+	if p.ValueType() == TypeText {
+		p.Value = UnescapeText(p.Value)
+	}
+	return p, nil
+}
+
+var paramUnescaper = strings.NewReplacer(
+	`^^`, `^`,
+	`^n`, "\n",
+	`^'`, `"`,
+)
+
+var textUnescaper = strings.NewReplacer(
+	`\\`, `\`,
+	`\n`, "\n",
+	`\N`, "\n",
+	`\;`, `;`,
+	`\,`, `,`,
+)
+
+// UnescapeText reverses the escaping of a TEXT value.
+func UnescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
+
+// ParseDate parses a DATE value.
+func ParseDate(s string) (time.Time, error) {
+	t, err := time.Parse(dateFormat, s)
+	if err != nil {
+		return time.Time{}, errors.Errorf("malformed date `%s'", s)
+	}
+	return t, nil
+}
+
+// ParseDateTime parses a DATE-TIME value.
+// Values in UTC have a trailing Z, all other values are parsed in the specified location.
+func ParseDateTime(s string, loc *time.Location) (time.Time, error) {
+	if strings.HasSuffix(s, "Z") {
+		t, err := time.Parse(dateTimeFormatUTC, s)
+		if err != nil {
+			return time.Time{}, errors.Errorf("malformed date time `%s'", s)
+		}
+		return t, nil
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	t, err := time.ParseInLocation(dateTimeFormat, s, loc)
+	if err != nil {
+		return time.Time{}, errors.Errorf("malformed date time `%s'", s)
+	}
+	return t, nil
+}
+
+// ParseDuration parses a DURATION value, e.g. -PT10M.
+func ParseDuration(s string) (time.Duration, error) {
+	rest := s
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(rest, "-"):
+		sign = -1
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "+"):
+		rest = rest[1:]
+	}
+	if !strings.HasPrefix(rest, "P") || len(rest) < 3 {
+		return 0, errors.Errorf("malformed duration `%s'", s)
+	}
+	rest = rest[1:]
+
+	const day = time.Hour * 24
+	var d time.Duration
+	inTime := false
+	for rest != "" {
+		if rest[0] == 'T' {
+			inTime = true
+			rest = rest[1:]
+			continue
+		}
+		i := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+		if i <= 0 {
+			return 0, errors.Errorf("malformed duration `%s'", s)
+		}
+		n, err := strconv.Atoi(rest[:i])
+		if err != nil {
+			return 0, errors.Errorf("malformed duration `%s'", s)
+		}
+		unit := rest[i]
+		rest = rest[i+1:]
+		switch {
+		case unit == 'W' && !inTime:
+			d += time.Duration(n) * day * 7
+		case unit == 'D' && !inTime:
+			d += time.Duration(n) * day
+		case unit == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case unit == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case unit == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		default:
+			return 0, errors.Errorf("malformed duration `%s'", s)
+		}
+	}
+	return sign * d, nil
+}
+
+// ParseUTCOffset parses a UTC-OFFSET value, e.g. +0100, and returns the offset in seconds.
+func ParseUTCOffset(s string) (int, error) {
+	//nolint: gomnd // +HHMM or +HHMMSS
+	if len(s) != 5 && len(s) != 7 || (s[0] != '+' && s[0] != '-') {
+		return 0, errors.Errorf("malformed utc offset `%s'", s)
+	}
+	var parts [3]int
+	for i := 0; 1+2*i < len(s); i++ {
+		n, err := strconv.Atoi(s[1+2*i : 3+2*i])
+		if err != nil || n < 0 {
+			return 0, errors.Errorf("malformed utc offset `%s'", s)
+		}
+		parts[i] = n
+	}
+	//nolint: gomnd // convert hours, minutes and seconds to seconds
+	offset := parts[0]*3600 + parts[1]*60 + parts[2]
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return offset, nil
+}
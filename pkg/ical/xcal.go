@@ -0,0 +1,185 @@
+package ical
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const xcalNamespace = "urn:ietf:params:xml:ns:icalendar-2.0"
+
+// XCalEncoder writes components in the xCal format as specified in RFC 6321.
+type XCalEncoder struct {
+	w     io.Writer
+	err   error
+	stack []xcalComponent
+}
+
+type xcalComponent struct {
+	name             string
+	hasProperties    bool
+	propertiesClosed bool
+}
+
+// NewXCalEncoder creates a XCalEncoder that writes to w.
+func NewXCalEncoder(w io.Writer) *XCalEncoder {
+	return &XCalEncoder{w: w}
+}
+
+// Err returns the first error that occurred while writing.
+func (e *XCalEncoder) Err() error {
+	return e.err
+}
+
+// Begin starts a component, e.g. VEVENT.
+func (e *XCalEncoder) Begin(component string) {
+	if n := len(e.stack); n > 0 {
+		if !e.stack[n-1].propertiesClosed {
+			if e.stack[n-1].hasProperties {
+				e.write("</properties>")
+			}
+			e.write("<components>")
+			e.stack[n-1].propertiesClosed = true
+		}
+	} else {
+		e.write(xml.Header)
+		e.write(`<icalendar xmlns="` + xcalNamespace + `">`)
+	}
+	name := strings.ToLower(component)
+	e.write("<" + name + ">")
+	e.stack = append(e.stack, xcalComponent{name: name})
+}
+
+// End ends a component.
+func (e *XCalEncoder) End(string) {
+	n := len(e.stack)
+	if n == 0 {
+		if e.err == nil {
+			e.err = errors.New("end without begin")
+		}
+		return
+	}
+	c := e.stack[n-1]
+	if c.propertiesClosed {
+		e.write("</components>")
+	} else if c.hasProperties {
+		e.write("</properties>")
+	}
+	e.write("</" + c.name + ">")
+	e.stack = e.stack[:n-1]
+	if len(e.stack) == 0 {
+		e.write("</icalendar>\n")
+	}
+}
+
+// WriteProperty writes a property of the current component.
+func (e *XCalEncoder) WriteProperty(p *Property) {
+	n := len(e.stack)
+	if n == 0 {
+		if e.err == nil {
+			e.err = errors.New("property outside of a component")
+		}
+		return
+	}
+	if !e.stack[n-1].hasProperties {
+		e.write("<properties>")
+		e.stack[n-1].hasProperties = true
+	}
+	e.write(XCalProperty(p))
+}
+
+func (e *XCalEncoder) write(s string) {
+	if e.err != nil {
+		return
+	}
+	if _, err := io.WriteString(e.w, s); err != nil {
+		e.err = errors.WithStack(err)
+	}
+}
+
+// XCalProperty returns the xCal representation of the property.
+func XCalProperty(p *Property) string {
+	var sb strings.Builder
+	name := strings.ToLower(p.Name)
+	sb.WriteString("<" + name + ">")
+
+	hasParams := false
+	for _, param := range p.Params {
+		if strings.EqualFold(param.Name, "VALUE") {
+			continue
+		}
+		if !hasParams {
+			sb.WriteString("<parameters>")
+			hasParams = true
+		}
+		paramName := strings.ToLower(param.Name)
+		sb.WriteString("<" + paramName + ">")
+		valueType := "text"
+		switch paramName {
+		case "delegated-from", "delegated-to", "member", "sent-by":
+			valueType = "cal-address"
+		case "altrep", "dir":
+			valueType = "uri"
+		}
+		for _, v := range param.Values {
+			writeXCalElement(&sb, valueType, v)
+		}
+		sb.WriteString("</" + paramName + ">")
+	}
+	if hasParams {
+		sb.WriteString("</parameters>")
+	}
+
+	valueType := p.ValueType()
+	element := strings.ToLower(string(valueType))
+	switch valueType {
+	case TypeDate, TypeDateTime, TypeDuration:
+		for _, v := range strings.Split(p.Value, ",") {
+			writeXCalElement(&sb, element, extendedDateTime(v))
+		}
+	case TypePeriod:
+		for _, v := range strings.Split(p.Value, ",") {
+			start, end, _ := strings.Cut(v, "/")
+			sb.WriteString("<period>")
+			writeXCalElement(&sb, "start", extendedDateTime(start))
+			if strings.Contains(end, "P") {
+				writeXCalElement(&sb, "duration", end)
+			} else {
+				writeXCalElement(&sb, "end", extendedDateTime(end))
+			}
+			sb.WriteString("</period>")
+		}
+	case TypeUTCOffset:
+		writeXCalElement(&sb, element, extendedUTCOffset(p.Value))
+	case TypeRecur:
+		sb.WriteString("<recur>")
+		for _, part := range strings.Split(p.Value, ";") {
+			key, value, ok := strings.Cut(part, "=")
+			if !ok {
+				continue
+			}
+			key = strings.ToLower(key)
+			if key == "until" {
+				writeXCalElement(&sb, key, extendedDateTime(value))
+				continue
+			}
+			for _, v := range strings.Split(value, ",") {
+				writeXCalElement(&sb, key, v)
+			}
+		}
+		sb.WriteString("</recur>")
+	default:
+		writeXCalElement(&sb, element, p.Value)
+	}
+
+	sb.WriteString("</" + name + ">")
+	return sb.String()
+}
+
+func writeXCalElement(sb *strings.Builder, name, value string) {
+	sb.WriteString("<" + name + ">")
+	_ = xml.EscapeText(sb, []byte(value))
+	sb.WriteString("</" + name + ">")
+}
@@ -0,0 +1,49 @@
+package ical
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestXCalEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewXCalEncoder(&buf)
+	enc.Begin("VCALENDAR")
+	enc.WriteProperty(&Property{Name: "VERSION", Value: "2.0"})
+	enc.Begin("VEVENT")
+	enc.WriteProperty(&Property{Name: "DTSTART", Type: TypeDate, Value: "20080205"})
+	enc.WriteProperty(&Property{
+		Name:   "DTEND",
+		Params: []Param{{Name: "TZID", Values: []string{"Europe/Berlin"}}},
+		Value:  "20080206T100000",
+	})
+	enc.WriteProperty(&Property{Name: "RRULE", Value: "FREQ=WEEKLY;COUNT=10;BYDAY=TU,TH"})
+	enc.WriteProperty(&Property{Name: "SUMMARY", Value: "Lunch & <Learn>"})
+	enc.WriteProperty(&Property{Name: "TZOFFSETTO", Value: "+0100"})
+	enc.Begin("VALARM")
+	enc.End("VALARM")
+	enc.End("VEVENT")
+	enc.End("VCALENDAR")
+	require.NoError(t, enc.Err())
+
+	require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<icalendar xmlns="urn:ietf:params:xml:ns:icalendar-2.0">`+
+		`<vcalendar>`+
+		`<properties><version><text>2.0</text></version></properties>`+
+		`<components>`+
+		`<vevent>`+
+		`<properties>`+
+		`<dtstart><date>2008-02-05</date></dtstart>`+
+		`<dtend><parameters><tzid><text>Europe/Berlin</text></tzid></parameters><date-time>2008-02-06T10:00:00</date-time></dtend>`+
+		`<rrule><recur><freq>WEEKLY</freq><count>10</count><byday>TU</byday><byday>TH</byday></recur></rrule>`+
+		`<summary><text>Lunch &amp; &lt;Learn&gt;</text></summary>`+
+		`<tzoffsetto><utc-offset>+01:00</utc-offset></tzoffsetto>`+
+		`</properties>`+
+		`<components><valarm></valarm></components>`+
+		`</vevent>`+
+		`</components>`+
+		`</vcalendar>`+
+		"</icalendar>\n", buf.String())
+}